@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/auth"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Audited actions
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionStop           = "stop"
+	ActionRestart        = "restart"
+	ActionRun            = "run"
+	ActionRevoke         = "revoke"
+	ActionChangePassword = "change_password"
+)
+
+// Audited objects
+const (
+	ObjectMeasurement    = "measurement"
+	ObjectAlertRule      = "alert_rule"
+	ObjectReportSchedule = "report_schedule"
+	ObjectUser           = "user"
+	ObjectTeam           = "team"
+	ObjectAPIToken       = "api_token"
+	ObjectPolicy         = "policy"
+	ObjectDeniedPrefix   = "denied_prefix"
+)
+
+// Query limits
+const (
+	DefaultLogLimit = 100
+	MaxLogLimit     = 1000
+)
+
+type Filter struct {
+	Username   string
+	Action     string
+	ObjectType string
+	ObjectID   string
+	From       string
+	To         string
+	Limit      int
+}
+
+func encodeValue(value any) string {
+	if value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+func Record(c *gin.Context, action, objectType, objectID string, before, after any) {
+	user, _ := auth.CurrentUser(c)
+	entry := models.AuditLog{
+		Timestamp:  time.Now().Format(time.RFC3339),
+		UserID:     user.ID,
+		Username:   user.Username,
+		SourceIP:   c.GetString("clientIP"),
+		Action:     action,
+		ObjectType: objectType,
+		ObjectID:   objectID,
+		Before:     encodeValue(before),
+		After:      encodeValue(after),
+	}
+	if value, ok := c.Get("token"); ok {
+		entry.TokenPrefix = value.(models.APIToken).Prefix
+	}
+	// A failed audit write should not undo the change that was already made
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("[!] Could not record audit log entry: %s %s %s, %v", action, objectType, objectID, err)
+	}
+}
+
+func GetAuditLogs(filter Filter) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLogLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxLogLimit {
+		return entries, errors.Errorf("Limit: %d is not supported, value should be between 1 and %d.", filter.Limit, MaxLogLimit)
+	}
+	query := database.DB.Order("id desc").Limit(filter.Limit)
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ObjectType != "" {
+		query = query.Where("object_type = ?", filter.ObjectType)
+	}
+	if filter.ObjectID != "" {
+		query = query.Where("object_id = ?", filter.ObjectID)
+	}
+	// Entries are stored in local time, bounds in another offset would not compare as strings
+	for _, bound := range []struct {
+		value, condition string
+	}{{filter.From, "timestamp >= ?"}, {filter.To, "timestamp <= ?"}} {
+		if bound.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return entries, errors.Errorf("Time: '%s' should be in RFC3339 format.", bound.value)
+		}
+		query = query.Where(bound.condition, parsed.Local().Format(time.RFC3339))
+	}
+	if err := query.Find(&entries).Error; err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
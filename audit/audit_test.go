@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+}
+
+func TestGetAuditLogsBounds(t *testing.T) {
+	openTestDB(t)
+	// Entries are stored in the server's zone, bounds are given in UTC
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+	for _, timestamp := range []string{"2024-01-01T10:00:00+02:00", "2024-01-01T12:00:00+02:00", "2024-01-01T14:00:00+02:00"} {
+		database.DB.Create(&models.AuditLog{Timestamp: timestamp, Action: ActionCreate})
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		want     int
+	}{
+		{"no bounds", "", "", 3},
+		{"utc range", "2024-01-01T09:30:00Z", "2024-01-01T10:30:00Z", 1},
+		{"utc from", "2024-01-01T10:00:00Z", "", 2},
+		{"other offset to", "", "2024-01-01T11:00:00+01:00", 2},
+	}
+	for _, test := range tests {
+		entries, err := GetAuditLogs(Filter{From: test.from, To: test.to})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(entries) != test.want {
+			t.Errorf("%s: got %d entries, want %d", test.name, len(entries), test.want)
+		}
+	}
+	if _, err := GetAuditLogs(Filter{From: "yesterday"}); err == nil {
+		t.Error("invalid bound was accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		perMinute int
+		requests  []time.Duration
+		allowed   []bool
+	}{
+		{"burst of one minute", 2, []time.Duration{0, 0, 0}, []bool{true, true, false}},
+		{"refill after half a minute", 2, []time.Duration{0, 0, 0, 30 * time.Second}, []bool{true, true, false, true}},
+		{"refill is capped at the burst", 2, []time.Duration{0, 0, 10 * time.Minute, 10 * time.Minute, 10 * time.Minute}, []bool{true, true, true, true, false}},
+		{"partial refill is not enough", 1, []time.Duration{0, 30 * time.Second}, []bool{true, false}},
+	}
+	for _, test := range tests {
+		limiter := newRateLimiter(test.perMinute)
+		for i, offset := range test.requests {
+			ok, retryAfter := limiter.allow("client", start.Add(offset))
+			if ok != test.allowed[i] {
+				t.Errorf("%s: request %d allowed %v, want %v", test.name, i, ok, test.allowed[i])
+			}
+			if !ok && retryAfter <= 0 {
+				t.Errorf("%s: request %d rejected without a retry delay", test.name, i)
+			}
+		}
+	}
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(1)
+	if ok, _ := limiter.allow("a", now); !ok {
+		t.Fatal("first request of a was rejected")
+	}
+	if ok, _ := limiter.allow("b", now); !ok {
+		t.Error("b shares the bucket of a")
+	}
+	if ok, _ := limiter.allow("a", now); ok {
+		t.Error("second request of a was allowed")
+	}
+}
+
+func TestLoginRateLimitRendersLoginPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("login.html").Parse("{{ .errors }}")))
+	router.POST("/login", LoginRateLimit(), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	var w *httptest.ResponseRecorder
+	for i := 0; i <= loginRateLimit; i++ {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d after %d attempts", w.Code, loginRateLimit+1)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("got content type %s", contentType)
+	}
+	if !strings.Contains(w.Body.String(), "Too many login attempts") || w.Header().Get("Retry-After") == "" {
+		t.Errorf("got %s", w.Body.String())
+	}
+}
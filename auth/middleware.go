@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sngx13/pingernoid/models"
+)
+
+func SessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, err := c.Cookie(SessionCookieName); err == nil && token != "" {
+			if session, user, err := GetSession(token); err == nil {
+				c.Set("session", session)
+				c.Set("user", user)
+				c.Set("username", user.Username)
+				c.Set("role", user.Role)
+			}
+		}
+		c.Next()
+	}
+}
+
+func TokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "message": "Authorization header should use the Bearer scheme"})
+			return
+		}
+		apiToken, user, err := ValidateAPIToken(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "message": err.Error()})
+			return
+		}
+		// A bearer token replaces any session, so CSRF checks do not apply
+		delete(c.Keys, "session")
+		c.Set("token", apiToken)
+		c.Set("user", user)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Next()
+	}
+}
+
+func RequireScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("token")
+		if !ok {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if value.(models.APIToken).Scope != ScopeWrite {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "API token is read-only"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("session"); !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "This action requires a logged in session"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func CurrentUser(c *gin.Context) (models.User, bool) {
+	user, ok := c.Get("user")
+	if !ok {
+		return models.User{}, false
+	}
+	return user.(models.User), true
+}
+
+func RequireWebAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentUser(c); !ok {
+			c.Redirect(http.StatusFound, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func RequireAPIAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentUser(c); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "message": "Authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func CSRFProtect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		value, ok := c.Get("session")
+		if !ok {
+			c.Next()
+			return
+		}
+		// Cookie authenticated requests have to echo the token only our own pages can read
+		session := value.(models.Session)
+		header := c.GetHeader(CSRFHeaderName)
+		if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(session.CSRFToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "Missing or invalid CSRF token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func SetSessionCookies(c *gin.Context, token string, session models.Session) {
+	maxAge := int(SessionTTL.Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, token, maxAge, "/", "", true, true)
+	// Readable by our scripts so HTMX can send it back as a header
+	c.SetCookie(CSRFCookieName, session.CSRFToken, maxAge, "/", "", true, false)
+}
+
+func ClearSessionCookies(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", "", true, true)
+	c.SetCookie(CSRFCookieName, "", -1, "/", "", true, false)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Rate limits, in requests per minute, 0 disables the limit
+const (
+	envClientRateLimit     = "PINGERNOID_RATE_LIMIT_CLIENT"
+	envTokenRateLimit      = "PINGERNOID_RATE_LIMIT_TOKEN"
+	defaultClientRateLimit = 120
+	defaultTokenRateLimit  = 300
+	// Password guessing gets a much tighter budget than the API
+	loginRateLimit = 10
+	// Idle buckets are dropped so the map does not grow with every address seen
+	rateLimitPruneInterval = 10 * time.Minute
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute float64
+	buckets   map[string]*bucket
+	pruned    time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: float64(perMinute), buckets: map[string]*bucket{}, pruned: time.Now()}
+}
+
+// Token bucket allowing a burst of one minute worth of requests
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.pruned) > rateLimitPruneInterval {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > time.Minute {
+				delete(l.buckets, k)
+			}
+		}
+		l.pruned = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.perMinute, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.perMinute, b.tokens+now.Sub(b.last).Minutes()*l.perMinute)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perMinute * float64(time.Minute))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func rateLimitFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Printf("[!] 'RateLimit' - Ignoring invalid %s: '%s', using %d requests per minute.", name, value, fallback)
+		return fallback
+	}
+	return limit
+}
+
+func RateLimit() gin.HandlerFunc {
+	return rateLimitMiddleware(rateLimitFromEnv(envClientRateLimit, defaultClientRateLimit), rateLimitFromEnv(envTokenRateLimit, defaultTokenRateLimit), func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests, "message": "Too many requests, please slow down"})
+	})
+}
+
+func LoginRateLimit() gin.HandlerFunc {
+	// The login form is a plain HTML form, it gets the page back with the error instead of JSON
+	return rateLimitMiddleware(loginRateLimit, loginRateLimit, func(c *gin.Context) {
+		c.HTML(http.StatusTooManyRequests, "login.html", gin.H{
+			"title":  "Login Page",
+			"next":   c.PostForm("next"),
+			"errors": fmt.Sprintf("Too many login attempts, please try again in %s seconds", c.Writer.Header().Get("Retry-After")),
+		})
+		c.Abort()
+	})
+}
+
+func rateLimitMiddleware(clientLimit, tokenLimit int, reject gin.HandlerFunc) gin.HandlerFunc {
+	clients, tokens := newRateLimiter(clientLimit), newRateLimiter(tokenLimit)
+	return func(c *gin.Context) {
+		limiter, limit, key := clients, clientLimit, c.ClientIP()
+		// Scripts using a token are limited per token wherever they run from
+		if value, ok := c.Get("token"); ok {
+			limiter, limit, key = tokens, tokenLimit, fmt.Sprint(value.(models.APIToken).ID)
+		}
+		if limit == 0 {
+			c.Next()
+			return
+		}
+		if ok, retryAfter := limiter.allow(key, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			reject(c)
+			return
+		}
+		c.Next()
+	}
+}
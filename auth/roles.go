@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/gorm"
+)
+
+// Roles, each one includes the permissions of the previous
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+func HasRole(user models.User, role string) bool {
+	return roleLevels[user.Role] >= roleLevels[role]
+}
+
+func CanViewMeasurement(user models.User, msr models.PingMeasurement) bool {
+	if HasRole(user, RoleAdmin) {
+		return true
+	}
+	// Measurements created before ownership existed are only visible to admins
+	return (msr.OwnerID != 0 && msr.OwnerID == user.ID) || (msr.TeamID != 0 && msr.TeamID == user.TeamID)
+}
+
+func CanManageMeasurement(user models.User, msr models.PingMeasurement) bool {
+	return HasRole(user, RoleOperator) && CanViewMeasurement(user, msr)
+}
+
+func VisibleMeasurements(db *gorm.DB, user models.User) *gorm.DB {
+	if HasRole(user, RoleAdmin) {
+		return db
+	}
+	if user.TeamID != 0 {
+		return db.Where("owner_id = ? OR team_id = ?", user.ID, user.TeamID)
+	}
+	return db.Where("owner_id = ?", user.ID)
+}
+
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := CurrentUser(c)
+		if !HasRole(user, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "Your role does not allow this action"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func RequireWebRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := CurrentUser(c)
+		if !HasRole(user, role) {
+			c.Redirect(http.StatusFound, "/")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func MeasurementAccess(manage bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := CurrentUser(c)
+		var msr models.PingMeasurement
+		// Unknown and hidden measurements look the same to the caller
+		if err := database.DB.First(&msr, "id = ?", c.Param("id")).Error; err != nil || !CanViewMeasurement(user, msr) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Measurement not found"})
+			return
+		}
+		if manage && !CanManageMeasurement(user, msr) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "Your role does not allow this action"})
+			return
+		}
+		c.Set("measurement", msr)
+		c.Next()
+	}
+}
+
+func MigrateRoles() {
+	// Accounts created before roles existed, the oldest one becomes the admin
+	var admins int64
+	database.DB.Model(&models.User{}).Where("role = ?", RoleAdmin).Count(&admins)
+	if admins == 0 {
+		var first models.User
+		if err := database.DB.Order("id").First(&first).Error; err == nil {
+			database.DB.Model(&first).Update("role", RoleAdmin)
+		}
+	}
+	database.DB.Model(&models.User{}).Where("role = '' OR role IS NULL").Update("role", RoleViewer)
+}
+
+func CreateTeam(name string) (models.Team, error) {
+	team := models.Team{Name: name, CreatedAt: time.Now().Format(time.RFC3339)}
+	if name == "" {
+		return team, errors.New("Please specify a name for the team.")
+	}
+	if err := database.DB.Create(&team).Error; err != nil {
+		return team, errors.Wrap(err, "Problem saving team to database.")
+	}
+	return team, nil
+}
+
+func GetTeams() ([]models.Team, error) {
+	var teams []models.Team
+	if err := database.DB.Order("name").Find(&teams).Error; err != nil {
+		return teams, err
+	}
+	return teams, nil
+}
+
+func GetUsers() ([]models.User, error) {
+	var users []models.User
+	if err := database.DB.Order("id").Find(&users).Error; err != nil {
+		return users, err
+	}
+	return users, nil
+}
+
+func UpdateUserAccess(userID int, role string, teamID int) (models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return user, errors.Errorf("User: %d not found.", userID)
+	}
+	if !ValidRole(role) {
+		return user, errors.Errorf("Role: '%s' is not supported, use one of viewer, operator, admin.", role)
+	}
+	if teamID != 0 {
+		if err := database.DB.First(&models.Team{}, "id = ?", teamID).Error; err != nil {
+			return user, errors.Errorf("Team: %d not found.", teamID)
+		}
+	}
+	user.Role, user.TeamID = role, uint(teamID)
+	if err := database.DB.Model(&user).Select("role", "team_id").Updates(&user).Error; err != nil {
+		return user, err
+	}
+	return user, nil
+}
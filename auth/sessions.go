@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Sessions
+const (
+	SessionCookieName = "pingernoid_session"
+	CSRFCookieName    = "pingernoid_csrf"
+	CSRFHeaderName    = "X-CSRF-Token"
+	SessionTTL        = 7 * 24 * time.Hour
+)
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	// Only the hash is stored, a leaked database does not hand out live sessions
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func CreateSession(user models.User) (string, models.Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", models.Session{}, errors.Wrap(err, "Could not generate session token.")
+	}
+	csrfToken, err := newToken()
+	if err != nil {
+		return "", models.Session{}, errors.Wrap(err, "Could not generate CSRF token.")
+	}
+	now := time.Now()
+	session := models.Session{
+		ID:        hashToken(token),
+		UserID:    user.ID,
+		CSRFToken: csrfToken,
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(SessionTTL).Format(time.RFC3339),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return "", session, errors.Wrap(err, "Problem saving session to database.")
+	}
+	return token, session, nil
+}
+
+func GetSession(token string) (models.Session, models.User, error) {
+	var session models.Session
+	var user models.User
+	if err := database.DB.First(&session, "id = ?", hashToken(token)).Error; err != nil {
+		return session, user, err
+	}
+	expiresAt, err := time.Parse(time.RFC3339, session.ExpiresAt)
+	if err != nil || time.Now().After(expiresAt) {
+		database.DB.Delete(&session)
+		return session, user, errors.New("Session has expired.")
+	}
+	if err := database.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+		return session, user, err
+	}
+	return session, user, nil
+}
+
+func DeleteSession(token string) error {
+	return database.DB.Where("id = ?", hashToken(token)).Delete(&models.Session{}).Error
+}
+
+func CleanupSessions() {
+	if err := database.DB.Where("expires_at < ?", time.Now().Format(time.RFC3339)).Delete(&models.Session{}).Error; err != nil {
+		log.Println("[!] 'CleanupSessions' - Could not remove expired sessions:", err)
+	}
+}
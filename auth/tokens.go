@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// API tokens
+const (
+	ScopeRead          = "read"
+	ScopeWrite         = "write"
+	TokenPrefix        = "pgn_"
+	MaxTokenExpiryDays = 365
+	tokenPrefixLength  = len(TokenPrefix) + 8
+	// Last use is only written once a minute to keep busy scripts from hammering the database
+	tokenLastUsedInterval = time.Minute
+)
+
+func CreateAPIToken(user models.User, name, scope string, expiryDays int) (string, models.APIToken, error) {
+	apiToken := models.APIToken{UserID: user.ID, Name: name, Scope: scope}
+	if name == "" {
+		return "", apiToken, errors.New("Please specify a name for the token.")
+	}
+	if scope != ScopeRead && scope != ScopeWrite {
+		return "", apiToken, errors.Errorf("Scope: '%s' is not supported, use one of read, write.", scope)
+	}
+	if expiryDays < 0 || expiryDays > MaxTokenExpiryDays {
+		return "", apiToken, errors.Errorf("Expiry: %d is not supported, value should be between 0 (never) and %d days.", expiryDays, MaxTokenExpiryDays)
+	}
+	secret, err := newToken()
+	if err != nil {
+		return "", apiToken, errors.Wrap(err, "Could not generate API token.")
+	}
+	token := TokenPrefix + secret
+	now := time.Now()
+	apiToken.Prefix = token[:tokenPrefixLength]
+	apiToken.TokenHash = hashToken(token)
+	apiToken.CreatedAt = now.Format(time.RFC3339)
+	apiToken.LastUsedAt = "Never"
+	if expiryDays > 0 {
+		apiToken.ExpiresAt = now.AddDate(0, 0, expiryDays).Format(time.RFC3339)
+	}
+	if err := database.DB.Create(&apiToken).Error; err != nil {
+		return "", apiToken, errors.Wrap(err, "Problem saving API token to database.")
+	}
+	return token, apiToken, nil
+}
+
+func GetAPITokens(user models.User) ([]models.APIToken, error) {
+	var apiTokens []models.APIToken
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id").Find(&apiTokens).Error; err != nil {
+		return apiTokens, err
+	}
+	return apiTokens, nil
+}
+
+func RevokeAPIToken(user models.User, tokenID int) error {
+	result := database.DB.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at = ''", tokenID, user.ID).
+		Update("revoked_at", time.Now().Format(time.RFC3339))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.Errorf("API token: %d not found or already revoked.", tokenID)
+	}
+	return nil
+}
+
+func ValidateAPIToken(token string) (models.APIToken, models.User, error) {
+	var apiToken models.APIToken
+	var user models.User
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return apiToken, user, errors.New("Malformed API token.")
+	}
+	if err := database.DB.First(&apiToken, "token_hash = ?", hashToken(token)).Error; err != nil {
+		return apiToken, user, errors.New("Invalid API token.")
+	}
+	now := time.Now()
+	if apiToken.RevokedAt != "" {
+		return apiToken, user, errors.New("API token has been revoked.")
+	}
+	if apiToken.ExpiresAt != "" {
+		if expiresAt, err := time.Parse(time.RFC3339, apiToken.ExpiresAt); err != nil || now.After(expiresAt) {
+			return apiToken, user, errors.New("API token has expired.")
+		}
+	}
+	if err := database.DB.First(&user, "id = ?", apiToken.UserID).Error; err != nil {
+		return apiToken, user, err
+	}
+	if lastUsed, err := time.Parse(time.RFC3339, apiToken.LastUsedAt); err != nil || now.Sub(lastUsed) > tokenLastUsedInterval {
+		apiToken.LastUsedAt = now.Format(time.RFC3339)
+		database.DB.Model(&apiToken).Update("last_used_at", apiToken.LastUsedAt)
+	}
+	return apiToken, user, nil
+}
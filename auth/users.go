@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Accounts
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MinPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	MaxPasswordLength = 72
+	envAdminUsername  = "PINGERNOID_ADMIN_USERNAME"
+	envAdminPassword  = "PINGERNOID_ADMIN_PASSWORD"
+)
+
+var (
+	errInvalidCredentials = errors.New("Invalid username or password.")
+	dummyPasswordHash, _  = bcrypt.GenerateFromPassword([]byte("pingernoid"), bcrypt.DefaultCost)
+)
+
+func validateCredentials(username, password string) error {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return errors.Errorf("Username should be between %d and %d characters.", MinUsernameLength, MaxUsernameLength)
+	}
+	return validatePassword(password)
+}
+
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return errors.Errorf("Password should be between %d and %d characters.", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+func CreateUser(username, password, role string, teamID uint) (models.User, error) {
+	user := models.User{Username: username, Role: role, TeamID: teamID}
+	if !ValidRole(role) {
+		return user, errors.Errorf("Role: '%s' is not supported, use one of viewer, operator, admin.", role)
+	}
+	if err := validateCredentials(username, password); err != nil {
+		return user, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return user, errors.Wrap(err, "Could not hash password.")
+	}
+	user.PasswordHash = string(hash)
+	user.CreatedAt = time.Now().Format(time.RFC3339)
+	user.LastLoginAt = "Never"
+	if err := database.DB.Create(&user).Error; err != nil {
+		return user, errors.Wrap(err, "Problem saving user to database.")
+	}
+	return user, nil
+}
+
+func Authenticate(username, password string) (models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, "username = ?", username).Error; err != nil {
+		// Compare against a dummy hash so unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return user, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return user, errInvalidCredentials
+	}
+	user.LastLoginAt = time.Now().Format(time.RFC3339)
+	if err := database.DB.Model(&user).Update("last_login_at", user.LastLoginAt).Error; err != nil {
+		log.Println("[!] 'Authenticate' - Could not update last login:", err)
+	}
+	return user, nil
+}
+
+func ChangePassword(user models.User, currentPassword, newPassword string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return errors.New("Current password is not correct.")
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "Could not hash password.")
+	}
+	if err := database.DB.Model(&user).Update("password_hash", string(hash)).Error; err != nil {
+		return err
+	}
+	// Every session of the user is signed out, the one changing the password included
+	return database.DB.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error
+}
+
+func BootstrapAdmin() {
+	var count int64
+	if err := database.DB.Model(&models.User{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	username, password := os.Getenv(envAdminUsername), os.Getenv(envAdminPassword)
+	if username == "" || password == "" {
+		log.Printf("[!] 'BootstrapAdmin' - No user accounts exist, set %s and %s to create the first one.", envAdminUsername, envAdminPassword)
+		return
+	}
+	if _, err := CreateUser(username, password, RoleAdmin, 0); err != nil {
+		log.Println("[!] 'BootstrapAdmin' - Could not create first user:", err)
+		return
+	}
+	log.Printf("[i] 'BootstrapAdmin' - Created first user: %s", username)
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sngx13/pingernoid/auth"
 	"github.com/sngx13/pingernoid/database"
 	"github.com/sngx13/pingernoid/models"
 	"github.com/sngx13/pingernoid/scheduler"
@@ -16,8 +22,14 @@ import (
 
 const (
 	LOCALHOST_443 = "0.0.0.0:443"
+	// Comma separated addresses or prefixes of reverse proxies allowed to set X-Forwarded-For
+	envTrustedProxies = "PINGERNOID_TRUSTED_PROXIES"
 )
 
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+var checkAPI = flag.Bool("check-api", false, "check the API routes and request bodies against "+views.OpenAPIPath+" and exit")
+
 func ClientIPMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the client's real IP address
@@ -46,7 +58,50 @@ func ClientIPMiddleware(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Keep the caller's ID when it is sane so requests can be traced across services
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set("requestID", requestID)
+		c.Header("X-Request-ID", requestID)
+		c.Next()
+	}
+}
+
+// Without trusted proxies the client address is the peer address, forwarded headers are ignored
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv(envTrustedProxies), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func checkAPIContract(router *gin.Engine) bool {
+	problems := views.CheckAPIContract(router.Routes(), "/api/v1")
+	for _, problem := range problems {
+		log.Printf("[!] 'CheckAPIContract' - %s", problem)
+	}
+	return len(problems) == 0
+}
+
 func main() {
+	flag.Parse()
+	if *checkAPI {
+		gin.SetMode(gin.ReleaseMode)
+		router := gin.New()
+		views.RegisterAPIRoutes(router.Group("/api/v1"))
+		if !checkAPIContract(router) {
+			os.Exit(1)
+		}
+		log.Println("[i] API routes and request bodies match the OpenAPI document.")
+		return
+	}
 	// Database
 	log.Println("[i] Performing database initialisation and model migrations.")
 	database.DBInit()
@@ -54,19 +109,50 @@ func main() {
 		&models.PingMeasurement{},
 		&models.MeasurementResults{},
 		&models.MeasurementResultAlerts{},
+		&models.MeasurementHopResults{},
+		&models.MeasurementPacketSamples{},
+		&models.AlertRule{},
+		&models.ReportSchedule{},
+		&models.GeneratedReport{},
+		&models.User{},
+		&models.Session{},
+		&models.APIToken{},
+		&models.Team{},
+		&models.AuditLog{},
+		&models.MeasurementPolicy{},
+		&models.DeniedPrefix{},
+		&models.MeasurementEvent{},
+		&models.MeasurementPaths{},
+		&models.MeasurementPathHops{},
+		&models.MeasurementResultPaths{},
 		&models.SiteVisitor{},
+		&models.IPMetadataCache{},
 	)
 	if err != nil {
 		log.Println("[!] Database migration error:", err)
 	}
+	utils.MigrateResultPaths()
 	// Housekeeping
 	scheduler.SchedulerHouseKeeping()
+	scheduler.ScheduleReports()
+	// Accounts
+	auth.MigrateRoles()
+	auth.BootstrapAdmin()
+	auth.CleanupSessions()
 	// Gin Router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	// Rate limits and visitor records are keyed on the client address, it must not be spoofable
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Printf("[!] 'SetTrustedProxies' - Ignoring invalid %s, %v", envTrustedProxies, err)
+		router.SetTrustedProxies(nil)
+	}
 	router.Use(gin.Recovery())
+	router.Use(RequestIDMiddleware())
 	// Use the custom middleware to extract and store the IP address
 	router.Use(ClientIPMiddleware(database.DB))
+	// Load the logged in user from the session cookie
+	router.Use(auth.SessionMiddleware())
 	// Use custom delims to prevent clashes with HTMX
 	router.Delims("{[{", "}]}")
 	// Templates
@@ -74,24 +160,28 @@ func main() {
 	// Static
 	router.Static("/static", "./static")
 	// API Endpoints
-	api_v1 := router.Group("/api/v1")
-	api_v1.POST("/checks/target/verify", views.ApiCheckTargetIP)
-	api_v1.GET("/measurements", views.ApiGetMeasurements)
-	api_v1.GET("/measurements/:id", views.ApiGetMeasurement)
-	api_v1.GET("/measurements/:id/traceroute/path", views.ApiGetMeasurementTracePathGraph)
-	api_v1.GET("/measurements/:id/alert/:timestamp", views.ApiGetAlertDetails)
-	api_v1.POST("/measurements/create", views.ApiCreateMeasurement)
-	api_v1.POST("/measurements/:id/stop", views.ApiStopMeasurement)
-	api_v1.POST("/measurements/:id/restart", views.ApiRestartMeasurement)
-	api_v1.DELETE("/measurements/:id/delete", views.ApiDeleteMeasurement)
-	api_v1.GET("/measurements/:id/results/combined/:time_range", views.ApiGetMeasurementCombinedChartResults)
-	api_v1.GET("/site/visitor/info/:ip", views.ApiGetVisitorInfo)
-	api_v1.GET("/site/visitor/info/chart", views.ApiGetVisitorsChart)
+	router.GET("/api/openapi.json", views.ApiGetOpenAPI)
+	// Login
+	router.GET("/login", views.WebLoginPage)
+	router.POST("/login", auth.LoginRateLimit(), views.WebLogin)
+	router.POST("/logout", auth.CSRFProtect(), views.WebLogout)
+	// Bearer tokens take precedence over the session cookie on the API
+	// Rate limits apply per token when one is used, otherwise per client address
+	rateLimit := auth.RateLimit()
+	api_v1 := router.Group("/api/v1", auth.TokenMiddleware(), rateLimit, auth.RequireAPIAuth(), auth.RequireScope(), auth.CSRFProtect())
+	views.RegisterAPIRoutes(api_v1)
+	// v2 serves the same handlers with real status codes and a uniform error object
+	api_v2 := router.Group("/api/v2", views.V2Envelope(), auth.TokenMiddleware(), rateLimit, auth.RequireAPIAuth(), auth.RequireScope(), auth.CSRFProtect())
+	views.RegisterAPIRoutes(api_v2)
+	// The spec is kept by hand, drift shows up in the log rather than stopping the server
+	checkAPIContract(router)
 	// WEB Endpoints
-	web_v1 := router.Group("/")
+	web_v1 := router.Group("/", auth.RequireWebAuth())
 	web_v1.GET("/", views.WebDashboardPage)
-	web_v1.GET("/visitors", views.WebVisitorsPage)
+	web_v1.GET("/visitors", auth.RequireWebRole(auth.RoleAdmin), views.WebVisitorsPage)
+	web_v1.GET("/audit", auth.RequireWebRole(auth.RoleAdmin), views.WebAuditPage)
 	web_v1.GET("/measurement/:id", views.WebGetMeasurement)
+	web_v1.GET("/reports/:period", views.WebGetReport)
 	router.NoRoute(func(c *gin.Context) {
 		c.Redirect(http.StatusPermanentRedirect, "/")
 	})
@@ -5,43 +5,201 @@ import (
 )
 
 type MeasurementResultAlerts struct {
-	MsrID          uuid.UUID `json:"msr_id" gorm:"type:uuid"`
+	MsrID          uuid.UUID `json:"msr_id" gorm:"type:uuid;index"`
 	AlertTimestamp string    `json:"alert_timestamp"`
 	AlertReason    string    `json:"alert_reason"`
 	AlertMessage   string    `json:"alert_message"`
+	AlertDetails   string    `json:"alert_details"`
+}
+
+type MeasurementPathHops struct {
+	PathID    uint   `json:"path_id" gorm:"index"`
+	HopIndex  int    `json:"hop_index"`
+	TTL       int    `json:"ttl"`
+	IPAddress string `json:"ip_address"`
+	ASN       string `json:"asn"`
+	PTR       string `json:"ptr"`
+}
+
+type MeasurementPaths struct {
+	ID        uint                  `json:"id" gorm:"primary_key"`
+	MsrID     uuid.UUID             `json:"msr_id" gorm:"type:uuid;uniqueIndex:idx_msr_path_hash"`
+	PathHash  string                `json:"path_hash" gorm:"uniqueIndex:idx_msr_path_hash"`
+	FirstSeen string                `json:"first_seen"`
+	LastSeen  string                `json:"last_seen"`
+	TimesSeen int                   `json:"times_seen"`
+	Hops      []MeasurementPathHops `json:"hops" gorm:"foreignkey:PathID;constraint:OnDelete:CASCADE"`
+}
+
+type MeasurementResultPaths struct {
+	MsrID     uuid.UUID `json:"msr_id" gorm:"type:uuid"`
+	Timestamp string    `json:"timestamp"`
+	PathID    uint      `json:"path_id"`
+	FlowID    int       `json:"flow_id"`
 }
 
 type MeasurementResults struct {
-	MsrID        uuid.UUID `json:"msr_id" gorm:"type:uuid"`
-	Timestamp    string    `json:"timestamp"`
-	Rcvd         int       `json:"rcvd"`
-	Sent         int       `json:"sent"`
-	Loss         float64   `json:"loss"`
-	AvgRtt       float64   `json:"avg_rtt"`
-	MinRtt       float64   `json:"min_rtt"`
-	MaxRtt       float64   `json:"max_rtt"`
-	Jitter       float64   `json:"jitter"`
-	IPHopCount   int       `json:"ip_hop_count"`
-	ASHopCount   int       `json:"as_hop_count"`
-	IPPath       string    `json:"ip_path"`
-	ASPath       string    `json:"as_path"`
-	CombinedPath string    `json:"combined_path"`
-	Alerting     bool      `json:"alerting"`
+	MsrID      uuid.UUID `json:"msr_id" gorm:"type:uuid;index"`
+	Timestamp  string    `json:"timestamp"`
+	Rcvd       int       `json:"rcvd"`
+	Sent       int       `json:"sent"`
+	Loss       float64   `json:"loss"`
+	AvgRtt     float64   `json:"avg_rtt"`
+	MinRtt     float64   `json:"min_rtt"`
+	MaxRtt     float64   `json:"max_rtt"`
+	Jitter     float64   `json:"jitter"`
+	IPHopCount int       `json:"ip_hop_count"`
+	ASHopCount int       `json:"as_hop_count"`
+	PathID     uint      `json:"path_id"`
+	PathMtu    int       `json:"path_mtu"`
+	RFactor    float64   `json:"r_factor"`
+	MOS        float64   `json:"mos"`
+	Alerting   bool      `json:"alerting"`
+}
+
+type MeasurementHopResults struct {
+	MsrID     uuid.UUID `json:"msr_id" gorm:"type:uuid"`
+	Timestamp string    `json:"timestamp"`
+	Hop       int       `json:"hop"`
+	IPAddress string    `json:"ip_address"`
+	ASN       string    `json:"asn"`
+	PTR       string    `json:"ptr"`
+	Sent      int       `json:"sent"`
+	Rcvd      int       `json:"rcvd"`
+	Loss      float64   `json:"loss"`
+	MinRtt    float64   `json:"min_rtt"`
+	AvgRtt    float64   `json:"avg_rtt"`
+	MaxRtt    float64   `json:"max_rtt"`
+}
+
+type MeasurementPacketSamples struct {
+	MsrID     uuid.UUID `json:"msr_id" gorm:"type:uuid;index"`
+	Timestamp string    `json:"timestamp" gorm:"index"`
+	Seq       int       `json:"seq"`
+	Received  bool      `json:"received"`
+	Rtt       float64   `json:"rtt"`
+}
+
+type AlertRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	MsrID     uuid.UUID `json:"msr_id" gorm:"type:uuid;index"`
+	CreatedAt string    `json:"created_at"`
+	Metric    string    `json:"metric"`
+	Operator  string    `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Window    int       `json:"window"`
+}
+
+type ReportSchedule struct {
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	CreatedAt    string  `json:"created_at"`
+	OwnerID      uint    `json:"owner_id" gorm:"index"`
+	Name         string  `json:"name"`
+	Period       string  `json:"period"`
+	MsrIDs       string  `json:"msr_ids"`
+	Channel      string  `json:"channel"`
+	Destination  string  `json:"destination"`
+	SlaMaxAvgRtt float64 `json:"sla_max_avg_rtt"`
+	SlaMaxLoss   float64 `json:"sla_max_loss"`
+	LastRunAt    string  `json:"last_run_at"`
+	NextRunAt    string  `json:"next_run_at"`
+}
+
+type GeneratedReport struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	ScheduleID    uint   `json:"schedule_id" gorm:"index"`
+	GeneratedAt   string `json:"generated_at"`
+	Period        string `json:"period"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Delivered     bool   `json:"delivered"`
+	DeliveryError string `json:"delivery_error"`
+	HTML          string `json:"-"`
+	CSV           string `json:"-"`
+}
+
+type User struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	CreatedAt    string `json:"created_at"`
+	Username     string `json:"username" gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	TeamID       uint   `json:"team_id" gorm:"index"`
+	LastLoginAt  string `json:"last_login_at"`
+}
+
+type Team struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	CreatedAt string `json:"created_at"`
+	Name      string `json:"name" gorm:"uniqueIndex"`
+}
+
+type Session struct {
+	ID        string `json:"-" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"index"`
+	CSRFToken string `json:"-"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type APIToken struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	UserID     uint   `json:"user_id" gorm:"index"`
+	Name       string `json:"name"`
+	Prefix     string `json:"prefix"`
+	TokenHash  string `json:"-" gorm:"uniqueIndex"`
+	Scope      string `json:"scope"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at"`
+	LastUsedAt string `json:"last_used_at"`
+	RevokedAt  string `json:"revoked_at"`
 }
 
 type PingMeasurement struct {
-	ID          uuid.UUID                 `json:"id" gorm:"primary_key;type:uuid"`
-	CreatedAt   string                    `json:"created_at"`
-	LastPollAt  string                    `json:"last_poll_at"`
-	StoppedAt   string                    `json:"stopped_at"`
-	Target      string                    `json:"target" gorm:"unique"`
-	PacketCount int                       `json:"packet_count"`
-	IsHostname  bool                      `json:"is_hostname"`
-	Frequency   int                       `json:"frequency"`
-	Results     []MeasurementResults      `json:"results" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
-	Alerts      []MeasurementResultAlerts `json:"alerts" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
-	Status      int                       `json:"status"`
-	StatusName  string                    `json:"status_name"`
+	ID              uuid.UUID                  `json:"id" gorm:"primary_key;type:uuid"`
+	CreatedAt       string                     `json:"created_at"`
+	LastPollAt      string                     `json:"last_poll_at"`
+	StoppedAt       string                     `json:"stopped_at"`
+	OwnerID         uint                       `json:"owner_id" gorm:"index"`
+	TeamID          uint                       `json:"team_id" gorm:"index"`
+	Target          string                     `json:"target" gorm:"unique"`
+	Tags            string                     `json:"tags"`
+	PacketCount     int                        `json:"packet_count"`
+	PacketSize      int                        `json:"packet_size"`
+	Interval        int                        `json:"interval"`
+	TTL             int                        `json:"ttl"`
+	Timeout         int                        `json:"timeout"`
+	DSCP            int                        `json:"dscp"`
+	ThresholdMinRtt float64                    `json:"threshold_min_rtt"`
+	ThresholdMaxRtt float64                    `json:"threshold_max_rtt"`
+	ThresholdAvgRtt float64                    `json:"threshold_avg_rtt"`
+	ThresholdJitter float64                    `json:"threshold_jitter"`
+	ThresholdLoss   float64                    `json:"threshold_loss"`
+	IsHostname      bool                       `json:"is_hostname"`
+	Frequency       int                        `json:"frequency"`
+	MtrMode         bool                       `json:"mtr_mode"`
+	Multipath       bool                       `json:"multipath"`
+	PmtuMode        bool                       `json:"pmtu_mode"`
+	Results         []MeasurementResults       `json:"results" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
+	Alerts          []MeasurementResultAlerts  `json:"alerts" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
+	HopResults      []MeasurementHopResults    `json:"hop_results,omitempty" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
+	ResultPaths     []MeasurementResultPaths   `json:"result_paths,omitempty" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
+	Samples         []MeasurementPacketSamples `json:"samples,omitempty" gorm:"foreignkey:MsrID;constraint:OnDelete:CASCADE"`
+	Status          int                        `json:"status"`
+	StatusName      string                     `json:"status_name"`
+}
+
+type IPMetadataCache struct {
+	IPAddress    string `json:"ip_address" gorm:"primary_key"`
+	ISP          string `json:"isp"`
+	ASN          string `json:"asn"`
+	Country      string `json:"country"`
+	CountryCode  string `json:"country_code"`
+	Failed       bool   `json:"failed"`
+	FetchedAt    string `json:"fetched_at"`
+	ExpiresAt    string `json:"expires_at"`
+	PTR          string `json:"ptr"`
+	PTRExpiresAt string `json:"ptr_expires_at"`
 }
 
 type SiteVisitor struct {
@@ -51,3 +209,42 @@ type SiteVisitor struct {
 	Country     string `json:"country"`
 	CountryCode string `json:"country_code"`
 }
+
+type AuditLog struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Timestamp   string `json:"timestamp" gorm:"index"`
+	UserID      uint   `json:"user_id" gorm:"index"`
+	Username    string `json:"username"`
+	TokenPrefix string `json:"token_prefix"`
+	SourceIP    string `json:"source_ip"`
+	Action      string `json:"action" gorm:"index"`
+	ObjectType  string `json:"object_type" gorm:"index"`
+	ObjectID    string `json:"object_id" gorm:"index"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+}
+
+type MeasurementPolicy struct {
+	ID                     uint   `json:"-" gorm:"primaryKey"`
+	UpdatedAt              string `json:"updated_at"`
+	MinFrequency           int    `json:"min_frequency"`
+	MaxPacketCount         int    `json:"max_packet_count"`
+	MaxMeasurementsPerUser int    `json:"max_measurements_per_user"`
+}
+
+type DeniedPrefix struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	CreatedAt string `json:"created_at"`
+	Prefix    string `json:"prefix" gorm:"uniqueIndex"`
+	Reason    string `json:"reason"`
+}
+
+type MeasurementEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	MsrID     uuid.UUID `json:"msr_id" gorm:"index;type:uuid"`
+	Timestamp string    `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Username  string    `json:"username"`
+	Message   string    `json:"message"`
+	Changes   string    `json:"changes"`
+}
@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SMTP settings are read from the environment
+const (
+	envSMTPHost     = "PINGERNOID_SMTP_HOST"
+	envSMTPPort     = "PINGERNOID_SMTP_PORT"
+	envSMTPUsername = "PINGERNOID_SMTP_USERNAME"
+	envSMTPPassword = "PINGERNOID_SMTP_PASSWORD"
+	envSMTPFrom     = "PINGERNOID_SMTP_FROM"
+	defaultSMTPPort = "587"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+type smtpConfig struct {
+	host, port, username, password, from string
+}
+
+func loadSMTPConfig() (smtpConfig, error) {
+	config := smtpConfig{
+		host:     os.Getenv(envSMTPHost),
+		port:     os.Getenv(envSMTPPort),
+		username: os.Getenv(envSMTPUsername),
+		password: os.Getenv(envSMTPPassword),
+		from:     os.Getenv(envSMTPFrom),
+	}
+	if config.host == "" || config.from == "" {
+		return config, errors.Errorf("Email delivery is not configured, set %s and %s.", envSMTPHost, envSMTPFrom)
+	}
+	if config.port == "" {
+		config.port = defaultSMTPPort
+	}
+	return config, nil
+}
+
+func buildMessage(from string, to []string, subject, htmlBody string, attachments []Attachment) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=UTF-8"}})
+	if err != nil {
+		return nil, err
+	}
+	part.Write([]byte(htmlBody))
+	for _, attachment := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.Filename)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		// RFC 2045 limits encoded lines to 76 characters
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	// Line breaks would end the header and let the subject add its own
+	subject = strings.Join(strings.FieldsFunc(subject, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+func SendEmail(to []string, subject, htmlBody string, attachments []Attachment) error {
+	if len(to) == 0 {
+		return errors.New("No email recipients provided.")
+	}
+	config, err := loadSMTPConfig()
+	if err != nil {
+		return err
+	}
+	message, err := buildMessage(config.from, to, subject, htmlBody, attachments)
+	if err != nil {
+		return errors.Wrap(err, "Could not build email message.")
+	}
+	var auth smtp.Auth
+	if config.username != "" {
+		auth = smtp.PlainAuth("", config.username, config.password, config.host)
+	}
+	if err := smtp.SendMail(config.host+":"+config.port, auth, config.from, to, message); err != nil {
+		return errors.Wrap(err, "Could not send email.")
+	}
+	return nil
+}
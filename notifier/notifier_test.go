@@ -0,0 +1,37 @@
+package notifier
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildMessageSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"plain", "Pingernoid report: weekly (week)", "Pingernoid report: weekly (week)"},
+		{"header injection", "weekly\r\nBcc: attacker@example.com", "weekly Bcc: attacker@example.com"},
+		{"non ascii", "Rapport hebdomadaire é", "Rapport hebdomadaire é"},
+	}
+	for _, test := range tests {
+		raw, err := buildMessage("from@example.com", []string{"to@example.com"}, test.subject, "<p>report</p>", nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		message, err := mail.ReadMessage(strings.NewReader(string(raw)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if bcc := message.Header.Get("Bcc"); bcc != "" {
+			t.Errorf("%s: injected Bcc %s", test.name, bcc)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+		if err != nil || subject != test.want {
+			t.Errorf("%s: got subject %q, want %q", test.name, subject, test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package pinger
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/models"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func newMarkedProbe(id, seq uint16, size int) ([]byte, error) {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: int(id), Seq: int(seq), Data: make([]byte, size)},
+	}
+	return msg.Marshal(nil)
+}
+
+func markedPing(ctx context.Context, msr models.PingMeasurement) ([]PacketSample, error) {
+	// pro-bing can not set the TOS byte, DSCP marked probes are sent over a raw socket instead
+	ipAddr, err := net.ResolveIPAddr("ip4", msr.Target)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not resolve target.")
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not open raw ICMP socket.")
+	}
+	defer conn.Close()
+	if err := conn.IPv4PacketConn().SetTOS(msr.DSCP << 2); err != nil {
+		return nil, errors.Wrap(err, "Could not set DSCP marking.")
+	}
+	if err := conn.IPv4PacketConn().SetTTL(msr.TTL); err != nil {
+		return nil, errors.Wrap(err, "Could not set TTL.")
+	}
+	id := nextICMPID()
+	var mu sync.Mutex
+	sentAt := make(map[uint16]time.Time)
+	rtts := make(map[uint16]time.Duration)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			receivedAt := time.Now()
+			msg, err := icmp.ParseMessage(1, buf[:n])
+			if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+				continue
+			}
+			echo, ok := msg.Body.(*icmp.Echo)
+			if !ok || uint16(echo.ID) != id {
+				continue
+			}
+			seq := uint16(echo.Seq)
+			mu.Lock()
+			if sent, ok := sentAt[seq]; ok {
+				if _, duplicate := rtts[seq]; !duplicate {
+					rtts[seq] = receivedAt.Sub(sent)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+	deadline := time.Now().Add(time.Duration(msr.Timeout) * time.Second)
+	conn.SetReadDeadline(deadline)
+	sent := 0
+	for seq := uint16(0); int(seq) < msr.PacketCount && time.Now().Before(deadline); seq++ {
+		probe, err := newMarkedProbe(id, seq, msr.PacketSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not build probe.")
+		}
+		mu.Lock()
+		sentAt[seq] = time.Now()
+		mu.Unlock()
+		if _, err := conn.WriteTo(probe, ipAddr); err != nil {
+			return nil, errors.Wrap(err, "Could not send probe.")
+		}
+		sent++
+		if int(seq)+1 == msr.PacketCount {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(msr.Interval) * time.Millisecond):
+		}
+	}
+	// Stop waiting as soon as every probe has been answered
+	for {
+		mu.Lock()
+		answered := len(rtts) == sent
+		mu.Unlock()
+		if answered || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	conn.SetReadDeadline(time.Now())
+	<-done
+	samples := make([]PacketSample, sent)
+	for seq := range samples {
+		samples[seq].Seq = seq
+		samples[seq].Rtt, samples[seq].Received = rtts[uint16(seq)]
+	}
+	return samples, nil
+}
@@ -0,0 +1,84 @@
+package pinger
+
+import (
+	"time"
+)
+
+// MTR
+const (
+	mtrProbeCount = 10
+)
+
+type HopResult struct {
+	Hop       int
+	IPAddress string
+	ASN       string
+	PTR       string
+	Sent      int
+	Rcvd      int
+	Loss      float64
+	MinRtt    float64
+	AvgRtt    float64
+	MaxRtt    float64
+}
+
+func newHopResult(distance int, ipAddr string, rtts []time.Duration, probes int) HopResult {
+	hop := HopResult{
+		Hop:       distance,
+		IPAddress: ipAddr,
+		Sent:      probes,
+		Rcvd:      len(rtts),
+	}
+	if hop.Rcvd > hop.Sent {
+		hop.Rcvd = hop.Sent
+	}
+	if hop.Sent > 0 {
+		hop.Loss = float64(hop.Sent-hop.Rcvd) / float64(hop.Sent) * 100
+	}
+	if len(rtts) == 0 {
+		return hop
+	}
+	var total time.Duration
+	min, max := rtts[0], rtts[0]
+	for _, rtt := range rtts {
+		total += rtt
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+	}
+	hop.MinRtt = rttMilliseconds(min)
+	hop.MaxRtt = rttMilliseconds(max)
+	hop.AvgRtt = rttMilliseconds(total / time.Duration(len(rtts)))
+	return hop
+}
+
+// The probes of a TTL are shared by its ECMP responders, each is credited with its replies and a
+// proportional share of the unanswered probes, so a TTL's rows add up to the probes sent
+func newTTLHopResults(distance int, ipAddrs []string, rtts [][]time.Duration, probes int) []HopResult {
+	replies := 0
+	for _, nodeRtts := range rtts {
+		replies += len(nodeRtts)
+	}
+	unanswered := probes - replies
+	if unanswered < 0 || replies == 0 {
+		unanswered = 0
+	}
+	hops := make([]HopResult, len(ipAddrs))
+	credited := 0
+	for i, ipAddr := range ipAddrs {
+		share := 0
+		if replies > 0 {
+			share = unanswered * len(rtts[i]) / replies
+		}
+		credited += share
+		hops[i] = newHopResult(distance, ipAddr, rtts[i], len(rtts[i])+share)
+	}
+	// Rounding leftovers go to the first responder
+	if len(hops) > 0 && credited < unanswered {
+		hops[0] = newHopResult(distance, ipAddrs[0], rtts[0], hops[0].Sent+unanswered-credited)
+	}
+	return hops
+}
@@ -0,0 +1,259 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pixelbender/go-traceroute/traceroute"
+	"github.com/pkg/errors"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Paris traceroute
+const (
+	parisProbeCount   = 3
+	parisMaxHops      = 30
+	parisProbeDelay   = 10 * time.Millisecond
+	parisTimeout      = 2 * time.Second
+	multipathFlows    = 16
+	multipathPatience = 4
+	// Flows of a batch are traced at once, a poll spends at most this long on discovery
+	multipathBudget = 10 * time.Second
+)
+
+// Raw sockets receive every ICMP message of the host, so each probe run gets its own
+// identifier and traces their own sequence numbers to tell replies apart from concurrent ones
+var (
+	icmpIDs  = uint32(time.Now().UnixNano())
+	icmpSeqs = uint32(time.Now().UnixNano())
+)
+
+func nextICMPID() uint16 {
+	return uint16(atomic.AddUint32(&icmpIDs, 1))
+}
+
+func nextICMPSeq() uint16 {
+	return uint16(atomic.AddUint32(&icmpSeqs, 1))
+}
+
+type parisProbe struct {
+	ttl    int
+	sentAt time.Time
+}
+
+type MultipathTrace struct {
+	FlowID uint16
+	Hops   []*traceroute.Hop
+}
+
+func measurementFlowID(msrID uuid.UUID) uint16 {
+	// The flow is derived from the measurement so every poll hashes onto the same path
+	return binary.BigEndian.Uint16(msrID[2:4]) | 1
+}
+
+func flowICMPID(msrID uuid.UUID, flowID uint16) uint16 {
+	// Load balancers hashing the identifier too must see the same flow on every poll
+	return binary.BigEndian.Uint16(msrID[4:6]) ^ flowID
+}
+
+func onesComplementSum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return uint16(sum)
+}
+
+func newParisProbe(id, seq, flowID uint16) []byte {
+	// Load balancers hash the first four bytes of ICMP, the checksum is kept equal to the flow ID
+	// by adjusting two payload bytes, so only the sequence number changes between probes
+	b := make([]byte, 12)
+	b[0] = byte(ipv4.ICMPTypeEcho)
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	partial := uint32(onesComplementSum(b))
+	target := uint32(^flowID)
+	compensation := target + (^partial & 0xffff)
+	for compensation > 0xffff {
+		compensation = (compensation >> 16) + (compensation & 0xffff)
+	}
+	binary.BigEndian.PutUint16(b[8:], uint16(compensation))
+	binary.BigEndian.PutUint16(b[2:], flowID)
+	return b
+}
+
+func parseParisReply(b []byte, id uint16, target net.IP) (uint16, bool, bool) {
+	msg, err := icmp.ParseMessage(1, b)
+	if err != nil {
+		return 0, false, false
+	}
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != ipv4.ICMPTypeEchoReply || uint16(body.ID) != id {
+			return 0, false, false
+		}
+		return uint16(body.Seq), true, true
+	case *icmp.TimeExceeded:
+		return parseQuotedProbe(body.Data, id, target)
+	case *icmp.DstUnreach:
+		return parseQuotedProbe(body.Data, id, target)
+	}
+	return 0, false, false
+}
+
+func parseQuotedProbe(data []byte, id uint16, target net.IP) (uint16, bool, bool) {
+	if len(data) < ipv4.HeaderLen {
+		return 0, false, false
+	}
+	header, err := ipv4.ParseHeader(data)
+	if err != nil || !header.Dst.Equal(target) {
+		return 0, false, false
+	}
+	quoted := data[header.Len:]
+	if len(quoted) < 8 || quoted[0] != byte(ipv4.ICMPTypeEcho) || binary.BigEndian.Uint16(quoted[4:]) != id {
+		return 0, false, false
+	}
+	return binary.BigEndian.Uint16(quoted[6:]), false, true
+}
+
+func parisTrace(target net.IP, id, flowID uint16, count int) ([]*traceroute.Hop, error) {
+	target = target.To4()
+	if target == nil {
+		return nil, errors.New("Paris traceroute only supports IPv4 targets.")
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not open raw ICMP socket.")
+	}
+	defer conn.Close()
+	var mu sync.Mutex
+	probes := make(map[uint16]parisProbe)
+	hopsByDistance := make(map[int]*traceroute.Hop)
+	replyDistance := parisMaxHops + 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			receivedAt := time.Now()
+			seq, fromTarget, ok := parseParisReply(buf[:n], id, target)
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			probe, sent := probes[seq]
+			if sent {
+				hop, exists := hopsByDistance[probe.ttl]
+				if !exists {
+					hop = &traceroute.Hop{Distance: probe.ttl}
+					hopsByDistance[probe.ttl] = hop
+				}
+				hop.Add(&traceroute.Reply{IP: from.(*net.IPAddr).IP, RTT: receivedAt.Sub(probe.sentAt), Hops: probe.ttl})
+				if fromTarget && probe.ttl < replyDistance {
+					replyDistance = probe.ttl
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+	for round := 0; round < count; round++ {
+		for ttl := 1; ttl <= parisMaxHops; ttl++ {
+			mu.Lock()
+			reached := ttl > replyDistance
+			mu.Unlock()
+			if reached {
+				break
+			}
+			if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+				return nil, errors.Wrap(err, "Could not set probe TTL.")
+			}
+			seq := nextICMPSeq()
+			mu.Lock()
+			probes[seq] = parisProbe{ttl: ttl, sentAt: time.Now()}
+			mu.Unlock()
+			if _, err := conn.WriteTo(newParisProbe(id, seq, flowID), &net.IPAddr{IP: target}); err != nil {
+				return nil, errors.Wrap(err, "Could not send probe.")
+			}
+			time.Sleep(parisProbeDelay)
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(parisTimeout))
+	<-done
+	var hops []*traceroute.Hop
+	for distance, hop := range hopsByDistance {
+		// The target answers every probe with TTL beyond its distance
+		if distance <= replyDistance {
+			hops = append(hops, hop)
+		}
+	}
+	sort.Slice(hops, func(i, j int) bool {
+		return hops[i].Distance < hops[j].Distance
+	})
+	return hops, nil
+}
+
+func traceKey(hops []*traceroute.Hop) string {
+	key := ""
+	for _, h := range hops {
+		for _, n := range h.Nodes {
+			key += n.IP.String() + ","
+		}
+	}
+	return key
+}
+
+func multipathTrace(target net.IP, msrID uuid.UUID, baseHops []*traceroute.Hop) ([]MultipathTrace, error) {
+	flowID := measurementFlowID(msrID)
+	var traces []MultipathTrace
+	// The base flow is the main trace of the poll, only other branches are reported
+	seen := map[string]bool{traceKey(baseHops): true}
+	deadline := time.Now().Add(multipathBudget)
+	for flow := 1; flow < multipathFlows && time.Now().Before(deadline); flow += multipathPatience {
+		batch := make([]MultipathTrace, 0, multipathPatience)
+		for offset := 0; offset < multipathPatience && flow+offset < multipathFlows; offset++ {
+			batch = append(batch, MultipathTrace{FlowID: flowID + uint16(flow+offset)*2})
+		}
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				batch[i].Hops, errs[i] = parisTrace(target, flowICMPID(msrID, batch[i].FlowID), batch[i].FlowID, 1)
+			}(i)
+		}
+		wg.Wait()
+		// Stop enumerating once a whole batch of flows produces no new branch
+		found := false
+		for i, trace := range batch {
+			if errs[i] != nil {
+				return traces, errs[i]
+			}
+			if key := traceKey(trace.Hops); !seen[key] {
+				seen[key] = true
+				found = true
+				traces = append(traces, trace)
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return traces, nil
+}
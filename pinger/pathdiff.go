@@ -0,0 +1,177 @@
+package pinger
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/utils"
+)
+
+// Reported by the ASN lookup when it fails
+const unknownASN = "N/A"
+
+// TTL is set for IP path changes, Position (1-based) for AS path changes
+type HopChange struct {
+	TTL      int    `json:"ttl,omitempty"`
+	Position int    `json:"position,omitempty"`
+	Previous string `json:"previous,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+type PathDiff struct {
+	Added   []HopChange `json:"added"`
+	Removed []HopChange `json:"removed"`
+	Changed []HopChange `json:"changed"`
+}
+
+func (d PathDiff) isEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func diffPaths(previous, current []string) PathDiff {
+	diff := PathDiff{}
+	// Longest common subsequence keeps unchanged hops aligned
+	lcs := make([][]int, len(previous)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(current)+1)
+	}
+	for i := len(previous) - 1; i >= 0; i-- {
+		for j := len(current) - 1; j >= 0; j-- {
+			if previous[i] == current[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var removed, added []HopChange
+	flush := func() {
+		// Hops replaced in place are reported as changed, the remainder as added or removed
+		for len(removed) > 0 && len(added) > 0 {
+			diff.Changed = append(diff.Changed, HopChange{Position: added[0].Position, Previous: removed[0].Previous, Current: added[0].Current})
+			removed, added = removed[1:], added[1:]
+		}
+		diff.Removed = append(diff.Removed, removed...)
+		diff.Added = append(diff.Added, added...)
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(previous) || j < len(current) {
+		switch {
+		case i < len(previous) && j < len(current) && previous[i] == current[j]:
+			flush()
+			i++
+			j++
+		case j < len(current) && (i == len(previous) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, HopChange{Position: j + 1, Current: current[j]})
+			j++
+		default:
+			removed = append(removed, HopChange{Position: i + 1, Previous: previous[i]})
+			i++
+		}
+	}
+	flush()
+	return diff
+}
+
+func knownASN(asn string) bool {
+	return asn != "" && asn != unknownASN
+}
+
+func asPathFromHops(hops []models.MeasurementPathHops) []string {
+	// Failed lookups carry no information, they neither break nor change the AS path
+	var asPath []string
+	for _, hop := range hops {
+		if _, ok := utils.SpecialPurposeLabel(hop.IPAddress); ok || hop.IPAddress == utils.TimeoutHop || !knownASN(hop.ASN) {
+			continue
+		}
+		asPath = append(asPath, hop.ASN)
+	}
+	return utils.RemoveDuplicates(asPath)
+}
+
+type ttlResponders struct {
+	ips  []string
+	asns map[string]bool
+}
+
+func respondersByTTL(hops []models.MeasurementPathHops) (map[int]*ttlResponders, int) {
+	responders := make(map[int]*ttlResponders)
+	last := 0
+	for _, hop := range hops {
+		if hop.TTL > last {
+			last = hop.TTL
+		}
+		if hop.IPAddress == utils.TimeoutHop {
+			continue
+		}
+		r, ok := responders[hop.TTL]
+		if !ok {
+			r = &ttlResponders{asns: make(map[string]bool)}
+			responders[hop.TTL] = r
+		}
+		r.ips = append(r.ips, hop.IPAddress)
+		r.asns[hop.ASN] = true
+	}
+	for _, r := range responders {
+		sort.Strings(r.ips)
+	}
+	return responders, last
+}
+
+// Same AS on both sides, unknown ASNs can not tell
+func sameKnownAS(previous, current map[string]bool) bool {
+	if len(previous) != 1 || len(current) != 1 {
+		return false
+	}
+	for asn := range previous {
+		return knownASN(asn) && current[asn]
+	}
+	return false
+}
+
+func diffHopsByTTL(previous, current []models.MeasurementPathHops) PathDiff {
+	diff := PathDiff{}
+	previousByTTL, previousLast := respondersByTTL(previous)
+	currentByTTL, currentLast := respondersByTTL(current)
+	last := previousLast
+	if currentLast > last {
+		last = currentLast
+	}
+	for ttl := 1; ttl <= last; ttl++ {
+		before, after := previousByTTL[ttl], currentByTTL[ttl]
+		switch {
+		case before == nil && after == nil:
+		case before == nil:
+			// A silent hop is no information, only a longer path adds hops
+			if ttl > previousLast {
+				diff.Added = append(diff.Added, HopChange{TTL: ttl, Current: strings.Join(after.ips, "|")})
+			}
+		case after == nil:
+			if ttl > currentLast {
+				diff.Removed = append(diff.Removed, HopChange{TTL: ttl, Previous: strings.Join(before.ips, "|")})
+			}
+		default:
+			// Any common responder means the same load balanced hop, as does a swap of routers inside one AS
+			if intersects(before.ips, after.ips) || sameKnownAS(before.asns, after.asns) {
+				continue
+			}
+			diff.Changed = append(diff.Changed, HopChange{TTL: ttl, Previous: strings.Join(before.ips, "|"), Current: strings.Join(after.ips, "|")})
+		}
+	}
+	return diff
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,10 +2,12 @@ package pinger
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
-	"strings"
+	"reflect"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,44 +16,45 @@ import (
 	"github.com/sngx13/pingernoid/database"
 	"github.com/sngx13/pingernoid/models"
 	"github.com/sngx13/pingernoid/utils"
-)
-
-// ICMP
-var (
-	icmpTimeout = 5
-	icmpTTL     = 64
+	"gorm.io/gorm"
 )
 
 type Alert struct {
 	AlertTimestamp string
 	AlertReason    string
 	AlertMessage   string
+	AlertDetails   string
 }
 
 type PingResult struct {
-	Sent   int
-	Rcvd   int
-	AvgRtt float64
-	MinRtt float64
-	MaxRtt float64
-	Loss   float64
-	Jitter float64
+	Sent    int
+	Rcvd    int
+	AvgRtt  float64
+	MinRtt  float64
+	MaxRtt  float64
+	Loss    float64
+	Jitter  float64
+	RFactor float64
+	MOS     float64
+	Samples []PacketSample
 }
 
 type TraceResult struct {
-	CurrentASPath       string
-	CurrentASHopCount   int
-	CurrentIPPath       string
-	CurrentIPHopCount   int
-	CurrentCombinedPath string
-	PreviousASPath      string
-	PreviousASHopCount  int
-	PreviousIPPath      int
-	PreviousIPHopCount  int
+	CurrentASHopCount int
+	CurrentIPHopCount int
+	Hops              []HopResult
+	PathHops          []models.MeasurementPathHops
+	PreviousPathHops  []models.MeasurementPathHops
+	Multipath         []MultipathPathHops
 }
 
-func (p *PingResult) icmpHealthCheck() (bool, Alert) {
-	if p.Rcvd != p.Sent {
+type MultipathPathHops struct {
+	FlowID   uint16
+	PathHops []models.MeasurementPathHops
+}
+
+func (p *PingResult) icmpHealthCheck(msr models.PingMeasurement) (bool, Alert) {
+	if p.Rcvd != p.Sent && p.Loss > msr.ThresholdLoss {
 		log.Printf("[i] 'icmpHealthCheck' - Packets received: %d is not the same as was sent: %d", p.Rcvd, p.Sent)
 		alert := Alert{
 			AlertTimestamp: time.Now().Format(time.RFC3339),
@@ -59,20 +62,20 @@ func (p *PingResult) icmpHealthCheck() (bool, Alert) {
 			AlertMessage:   fmt.Sprintf("Packets sent: %d received: %d", p.Sent, p.Rcvd),
 		}
 		return true, alert
-	} else if p.MinRtt > 50 || p.MaxRtt > 500 || p.AvgRtt > 100 {
-		log.Printf("[i] 'icmpHealthCheck' - Latency threshold exceeded:RTT (min) > 50ms / (max) > 500ms / (avg) > 100ms - current (min/max/avg): %fms %fms %fms", p.MinRtt, p.MaxRtt, p.AvgRtt)
+	} else if p.MinRtt > msr.ThresholdMinRtt || p.MaxRtt > msr.ThresholdMaxRtt || p.AvgRtt > msr.ThresholdAvgRtt {
+		log.Printf("[i] 'icmpHealthCheck' - Latency threshold exceeded:RTT (min) > %gms / (max) > %gms / (avg) > %gms - current (min/max/avg): %fms %fms %fms", msr.ThresholdMinRtt, msr.ThresholdMaxRtt, msr.ThresholdAvgRtt, p.MinRtt, p.MaxRtt, p.AvgRtt)
 		alert := Alert{
 			AlertTimestamp: time.Now().Format(time.RFC3339),
 			AlertReason:    "HIGH_LATENCY",
-			AlertMessage:   fmt.Sprintf("Rtt latency threshold reached (Min > 50 / Max > 500 / Avg > 100): Min: %fms, Max: %fms, Avg: %fms", p.MinRtt, p.MaxRtt, p.AvgRtt),
+			AlertMessage:   fmt.Sprintf("Rtt latency threshold reached (Min > %g / Max > %g / Avg > %g): Min: %fms, Max: %fms, Avg: %fms", msr.ThresholdMinRtt, msr.ThresholdMaxRtt, msr.ThresholdAvgRtt, p.MinRtt, p.MaxRtt, p.AvgRtt),
 		}
 		return true, alert
-	} else if p.Jitter > 25 {
-		log.Printf("[i] 'icmpHealthCheck' - Jitter threshold exceeded 25ms, current: %fms", p.Jitter)
+	} else if p.Jitter > msr.ThresholdJitter {
+		log.Printf("[i] 'icmpHealthCheck' - Jitter threshold exceeded %gms, current: %fms", msr.ThresholdJitter, p.Jitter)
 		alert := Alert{
 			AlertTimestamp: time.Now().Format(time.RFC3339),
 			AlertReason:    "HIGH_JITTER",
-			AlertMessage:   fmt.Sprintf("Jitter threshold exceeded 25ms: %fms", p.Jitter),
+			AlertMessage:   fmt.Sprintf("Jitter threshold exceeded %gms: %fms", msr.ThresholdJitter, p.Jitter),
 		}
 		return true, alert
 	}
@@ -80,75 +83,92 @@ func (p *PingResult) icmpHealthCheck() (bool, Alert) {
 }
 
 func (t *TraceResult) traceHealthCheck() (bool, Alert) {
-	if t.PreviousASHopCount > 1 && t.CurrentASHopCount > t.PreviousASHopCount {
-		log.Printf("[i] 'traceHealthCheck' - Current AS path: %s is longer than previous one: %s", t.CurrentASPath, t.PreviousASPath)
-		alert := Alert{
-			AlertTimestamp: time.Now().Format(time.RFC3339),
-			AlertReason:    "AS_PATH_CHANGE_LONGER",
-			AlertMessage:   fmt.Sprintf("AS Hop count has changed - current: %d, previous: %d", t.CurrentASHopCount, t.PreviousASHopCount),
-		}
-		return true, alert
-	} else if t.PreviousASHopCount > 1 && t.PreviousASHopCount > t.CurrentASHopCount {
-		log.Printf("[i] 'traceHealthCheck' - Current AS path: %s is shorter than previous one: %s", t.CurrentASPath, t.PreviousASPath)
-		alert := Alert{
-			AlertTimestamp: time.Now().Format(time.RFC3339),
-			AlertReason:    "AS_PATH_CHANGE_SHORTER",
-			AlertMessage:   fmt.Sprintf("AS Hop count has changed - current: %d, previous: %d", t.CurrentASHopCount, t.PreviousASHopCount),
-		}
-		return true, alert
-	} else if t.PreviousIPHopCount > 1 && t.CurrentIPHopCount > t.PreviousIPHopCount {
-		log.Printf("[i] 'traceHealthCheck' - Current IP path: %d is longer than previous one: %d", t.CurrentIPHopCount, t.PreviousIPHopCount)
+	if len(t.PreviousPathHops) == 0 || len(t.PathHops) == 0 {
+		return false, Alert{}
+	}
+	asDiff := diffPaths(asPathFromHops(t.PreviousPathHops), asPathFromHops(t.PathHops))
+	if !asDiff.isEmpty() {
+		currentASPath, _ := utils.PathSummary(t.PathHops)
+		previousASPath, _ := utils.PathSummary(t.PreviousPathHops)
+		log.Printf("[i] 'traceHealthCheck' - Current AS path: %s differs from previous one: %s", currentASPath, previousASPath)
+		details, _ := json.Marshal(asDiff)
 		alert := Alert{
 			AlertTimestamp: time.Now().Format(time.RFC3339),
-			AlertReason:    "IP_PATH_CHANGE_LONGER",
-			AlertMessage:   fmt.Sprintf("IP Hop count has changed - current: %d, previous: %d", t.CurrentIPHopCount, t.PreviousIPHopCount),
+			AlertReason:    "AS_PATH_CHANGE",
+			AlertMessage:   fmt.Sprintf("AS path has changed - added: %d, removed: %d, changed: %d", len(asDiff.Added), len(asDiff.Removed), len(asDiff.Changed)),
+			AlertDetails:   string(details),
 		}
 		return true, alert
-	} else if t.PreviousIPHopCount > 1 && t.PreviousIPHopCount > t.CurrentIPHopCount {
-		log.Printf("[i] 'traceHealthCheck' - Current IP path: %d is shorter than previous one: %d", t.CurrentIPHopCount, t.PreviousIPHopCount)
+	}
+	ipDiff := diffHopsByTTL(t.PreviousPathHops, t.PathHops)
+	if !ipDiff.isEmpty() {
+		_, currentIPPath := utils.PathSummary(t.PathHops)
+		_, previousIPPath := utils.PathSummary(t.PreviousPathHops)
+		log.Printf("[i] 'traceHealthCheck' - Current IP path: %s differs from previous one: %s", currentIPPath, previousIPPath)
+		details, _ := json.Marshal(ipDiff)
 		alert := Alert{
 			AlertTimestamp: time.Now().Format(time.RFC3339),
-			AlertReason:    "IP_PATH_CHANGE_SHORTER",
-			AlertMessage:   fmt.Sprintf("IP Hop count has changed - current: %d, previous: %d", t.CurrentIPHopCount, t.PreviousIPHopCount),
+			AlertReason:    "IP_PATH_CHANGE",
+			AlertMessage:   fmt.Sprintf("IP path has changed - added: %d, removed: %d, changed: %d", len(ipDiff.Added), len(ipDiff.Removed), len(ipDiff.Changed)),
+			AlertDetails:   string(details),
 		}
 		return true, alert
 	}
 	return false, Alert{}
 }
 
-func saveResult(msrID uuid.UUID, target string, pingResult PingResult, traceResult TraceResult) error {
+func saveResult(msrID uuid.UUID, target string, pingResult PingResult, traceResult TraceResult, pmtuResult PmtuResult) error {
 	var pingMsr models.PingMeasurement
 	if err := database.DB.Where("id = ?", msrID).First(&pingMsr).Error; err != nil {
 		log.Println("[!] 'saveResult' - Error loading existing measurement:", err)
 		return err
 	}
+	timestamp := time.Now().Format(time.RFC3339)
 	newResults := models.MeasurementResults{
-		MsrID:        msrID,
-		Timestamp:    time.Now().Format(time.RFC3339),
-		Rcvd:         pingResult.Rcvd,
-		Sent:         pingResult.Sent,
-		Loss:         pingResult.Loss,
-		AvgRtt:       pingResult.AvgRtt,
-		MinRtt:       pingResult.MinRtt,
-		MaxRtt:       pingResult.MaxRtt,
-		Jitter:       pingResult.Jitter,
-		IPHopCount:   traceResult.CurrentIPHopCount,
-		ASHopCount:   traceResult.CurrentASHopCount,
-		IPPath:       traceResult.CurrentIPPath,
-		ASPath:       traceResult.CurrentASPath,
-		CombinedPath: traceResult.CurrentCombinedPath,
-	}
-	pingMsr.LastPollAt = time.Now().Format(time.RFC3339)
-	pingMsr.Status = utils.StatusRunning
-	pingMsr.StatusName = utils.StatusNameRunning
+		MsrID:      msrID,
+		Timestamp:  timestamp,
+		Rcvd:       pingResult.Rcvd,
+		Sent:       pingResult.Sent,
+		Loss:       pingResult.Loss,
+		AvgRtt:     pingResult.AvgRtt,
+		MinRtt:     pingResult.MinRtt,
+		MaxRtt:     pingResult.MaxRtt,
+		Jitter:     pingResult.Jitter,
+		IPHopCount: traceResult.CurrentIPHopCount,
+		ASHopCount: traceResult.CurrentASHopCount,
+		PathMtu:    pmtuResult.CurrentPathMtu,
+		RFactor:    pingResult.RFactor,
+		MOS:        pingResult.MOS,
+	}
+	if path, err := utils.SaveMeasurementPath(msrID, traceResult.PathHops, timestamp); err != nil {
+		log.Println("[!] 'saveResult' - Could not catalogue path:", err)
+	} else {
+		newResults.PathID = path.ID
+	}
+	for _, branch := range traceResult.Multipath {
+		path, err := utils.SaveMeasurementPath(msrID, branch.PathHops, timestamp)
+		if err != nil {
+			log.Println("[!] 'saveResult' - Could not catalogue multipath branch:", err)
+			continue
+		}
+		pingMsr.ResultPaths = append(pingMsr.ResultPaths, models.MeasurementResultPaths{
+			MsrID:     msrID,
+			Timestamp: timestamp,
+			PathID:    path.ID,
+			FlowID:    int(branch.FlowID),
+		})
+	}
+	// Measurements created before thresholds were configurable are checked against the defaults
+	utils.ApplyPingDefaults(&pingMsr)
 	// Alerting
-	pingAlert, alertInfo := pingResult.icmpHealthCheck()
+	pingAlert, alertInfo := pingResult.icmpHealthCheck(pingMsr)
 	if pingAlert {
 		newResults.Alerting = true
 		newAlert := models.MeasurementResultAlerts{
 			AlertTimestamp: alertInfo.AlertTimestamp,
 			AlertReason:    alertInfo.AlertReason,
 			AlertMessage:   alertInfo.AlertMessage,
+			AlertDetails:   alertInfo.AlertDetails,
 		}
 		pingMsr.Alerts = append(pingMsr.Alerts, newAlert)
 	}
@@ -159,82 +179,255 @@ func saveResult(msrID uuid.UUID, target string, pingResult PingResult, traceResu
 			AlertTimestamp: alertInfo.AlertTimestamp,
 			AlertReason:    alertInfo.AlertReason,
 			AlertMessage:   alertInfo.AlertMessage,
+			AlertDetails:   alertInfo.AlertDetails,
+		}
+		pingMsr.Alerts = append(pingMsr.Alerts, newAlert)
+	}
+	pmtuAlert, alertInfo := pmtuResult.pmtuHealthCheck()
+	if pmtuAlert {
+		newResults.Alerting = true
+		newAlert := models.MeasurementResultAlerts{
+			AlertTimestamp: alertInfo.AlertTimestamp,
+			AlertReason:    alertInfo.AlertReason,
+			AlertMessage:   alertInfo.AlertMessage,
+			AlertDetails:   alertInfo.AlertDetails,
 		}
 		pingMsr.Alerts = append(pingMsr.Alerts, newAlert)
 	}
 	pingMsr.Results = append(pingMsr.Results, newResults)
-	if err := database.DB.Save(&pingMsr).Error; err != nil {
+	for _, sample := range pingResult.Samples {
+		pingMsr.Samples = append(pingMsr.Samples, models.MeasurementPacketSamples{
+			MsrID:     msrID,
+			Timestamp: timestamp,
+			Seq:       sample.Seq,
+			Received:  sample.Received,
+			Rtt:       rttMilliseconds(sample.Rtt),
+		})
+	}
+	for _, hop := range traceResult.Hops {
+		pingMsr.HopResults = append(pingMsr.HopResults, models.MeasurementHopResults{
+			MsrID:     msrID,
+			Timestamp: timestamp,
+			Hop:       hop.Hop,
+			IPAddress: hop.IPAddress,
+			ASN:       hop.ASN,
+			PTR:       hop.PTR,
+			Sent:      hop.Sent,
+			Rcvd:      hop.Rcvd,
+			Loss:      hop.Loss,
+			MinRtt:    hop.MinRtt,
+			AvgRtt:    hop.AvgRtt,
+			MaxRtt:    hop.MaxRtt,
+		})
+	}
+	if err := storePoll(pingMsr); err != nil {
 		log.Println("[!] 'saveResult' - Error updating measurement:", err)
 		return err
 	}
+	// Rules are evaluated over stored samples, so only once this poll has been saved
+	ruleAlerts := evaluateAlertRules(msrID)
+	for _, alertInfo := range ruleAlerts {
+		newAlert := models.MeasurementResultAlerts{
+			MsrID:          msrID,
+			AlertTimestamp: timestamp,
+			AlertReason:    alertInfo.AlertReason,
+			AlertMessage:   alertInfo.AlertMessage,
+		}
+		if err := database.DB.Create(&newAlert).Error; err != nil {
+			log.Println("[!] 'saveResult' - Error saving rule alert:", err)
+		}
+	}
+	if len(ruleAlerts) > 0 && !newResults.Alerting {
+		if err := database.DB.Model(&models.MeasurementResults{}).Where("msr_id = ? AND timestamp = ?", msrID, timestamp).Update("alerting", true).Error; err != nil {
+			log.Println("[!] 'saveResult' - Error flagging result as alerting:", err)
+		}
+	}
 	return nil
 }
 
-func traceIP(msrID uuid.UUID, target string) TraceResult {
-	var ipPath, asPath, combinedPath []string
-	hops, err := traceroute.Trace(net.ParseIP(target))
+// Inserts the rows of one poll and touches only the poll columns of the measurement,
+// settings changed while the poll was running are left as they are
+func storePoll(pingMsr models.PingMeasurement) error {
+	for i := range pingMsr.Alerts {
+		pingMsr.Alerts[i].MsrID = pingMsr.ID
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		rows := []any{pingMsr.Results, pingMsr.Alerts, pingMsr.Samples, pingMsr.HopResults, pingMsr.ResultPaths}
+		for _, row := range rows {
+			if reflect.ValueOf(row).Len() == 0 {
+				continue
+			}
+			if err := tx.Create(row).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.PingMeasurement{}).Where("id = ?", pingMsr.ID).Update("last_poll_at", time.Now().Format(time.RFC3339)).Error; err != nil {
+			return err
+		}
+		// A measurement stopped during the poll stays stopped
+		return tx.Model(&models.PingMeasurement{}).Where("id = ? AND status > ?", pingMsr.ID, utils.StatusStopped).Updates(map[string]any{
+			"status":      utils.StatusRunning,
+			"status_name": utils.StatusNameRunning,
+		}).Error
+	})
+}
+
+func hopASN(hopIP string) (string, bool) {
+	// Special-purpose hops are labelled locally and not counted as an AS hop
+	if label, ok := utils.SpecialPurposeLabel(hopIP); ok {
+		return label, false
+	}
+	_, asn, _, _ := utils.IPAddrLookupInfo(hopIP)
+	return asn, true
+}
+
+func hopIPs(hops []*traceroute.Hop) []string {
+	var ipAddrs []string
+	for _, h := range hops {
+		for _, n := range h.Nodes {
+			ipAddrs = append(ipAddrs, net.IP.String(n.IP))
+		}
+	}
+	return ipAddrs
+}
+
+func tracePathHops(hops []*traceroute.Hop, ptrs map[string]string) []models.MeasurementPathHops {
+	// One entry per responder of every TTL, TTLs without any reply are kept as timeouts
+	var pathHops []models.MeasurementPathHops
+	nextTTL := 1
+	for _, h := range hops {
+		for ; nextTTL < h.Distance; nextTTL++ {
+			pathHops = append(pathHops, models.MeasurementPathHops{TTL: nextTTL, IPAddress: utils.TimeoutHop})
+		}
+		nextTTL = h.Distance + 1
+		var responders []string
+		for _, n := range h.Nodes {
+			responders = append(responders, net.IP.String(n.IP))
+		}
+		// Sorted so the same responders always hash to the same path
+		sort.Strings(responders)
+		for _, hopIP := range utils.RemoveDuplicates(responders) {
+			asn, _ := hopASN(hopIP)
+			pathHops = append(pathHops, models.MeasurementPathHops{TTL: h.Distance, IPAddress: hopIP, ASN: asn, PTR: ptrs[hopIP]})
+		}
+	}
+	return pathHops
+}
+
+func traceIP(msrID uuid.UUID, target string, mtrMode, multipath bool) TraceResult {
+	var hopResults []HopResult
+	probes := parisProbeCount
+	if mtrMode {
+		probes = mtrProbeCount
+	}
+	flowID := measurementFlowID(msrID)
+	hops, err := parisTrace(net.ParseIP(target), flowICMPID(msrID, flowID), flowID, probes)
 	if err != nil {
 		log.Println("[!] 'tracePath' - Problem performing traceroute:", err)
 		return TraceResult{}
 	}
-	for _, h := range hops {
-		for _, n := range h.Nodes {
-			hopIP := net.IP.String(n.IP)
-			_, asn, _, _ := utils.IPAddrLookupInfo(hopIP)
-			ipPath = append(ipPath, hopIP)
-			asPath = append(asPath, asn)
-			combinedPath = append(combinedPath, fmt.Sprintf("%s (%s)", hopIP, asn))
+	ptrs := utils.ReverseLookupIPs(hopIPs(hops))
+	pathHops := tracePathHops(hops, ptrs)
+	if mtrMode {
+		asnByIP := make(map[string]string)
+		for _, hop := range pathHops {
+			asnByIP[hop.IPAddress] = hop.ASN
+		}
+		nextDistance := 1
+		for _, h := range hops {
+			// Record hops which did not answer any of the probes
+			for ; nextDistance < h.Distance; nextDistance++ {
+				hopResults = append(hopResults, newHopResult(nextDistance, "*", nil, probes))
+			}
+			nextDistance = h.Distance + 1
+			var ipAddrs []string
+			var rtts [][]time.Duration
+			for _, n := range h.Nodes {
+				ipAddrs = append(ipAddrs, net.IP.String(n.IP))
+				rtts = append(rtts, n.RTT)
+			}
+			for _, hopResult := range newTTLHopResults(h.Distance, ipAddrs, rtts, probes) {
+				hopResult.ASN = asnByIP[hopResult.IPAddress]
+				hopResult.PTR = ptrs[hopResult.IPAddress]
+				hopResults = append(hopResults, hopResult)
+			}
+		}
+	}
+	var multipathHops []MultipathPathHops
+	if multipath {
+		traces, err := multipathTrace(net.ParseIP(target), msrID, hops)
+		if err != nil {
+			log.Println("[!] 'tracePath' - Problem performing multipath discovery:", err)
+		}
+		for _, trace := range traces {
+			multipathHops = append(multipathHops, MultipathPathHops{FlowID: trace.FlowID, PathHops: tracePathHops(trace.Hops, utils.ReverseLookupIPs(hopIPs(trace.Hops)))})
 		}
 	}
-	asPath = utils.RemoveDuplicates(asPath)
-	previousPaths, err := utils.GetPreviousMsrResult(msrID)
+	previousPaths, err := utils.GetLatestPathResult(msrID)
 	if err != nil {
 		log.Println("[!] 'tracePath' - Could not get previous result", err)
 	}
+	var previousPathHops []models.MeasurementPathHops
+	if previousPaths.PathID != 0 {
+		if previousPath, err := utils.GetMeasurementPath(previousPaths.PathID); err == nil {
+			previousPathHops = previousPath.Hops
+		}
+	}
 	traceResult := TraceResult{
-		CurrentASPath:       strings.Join(asPath, " > "),
-		CurrentASHopCount:   len(asPath),
-		CurrentIPPath:       strings.Join(ipPath, " > "),
-		CurrentIPHopCount:   len(ipPath),
-		CurrentCombinedPath: strings.Join(combinedPath, " > "),
-		PreviousASPath:      previousPaths.ASPath,
-		PreviousASHopCount:  len(strings.Split(previousPaths.ASPath, ">")),
-		PreviousIPHopCount:  len(strings.Split(previousPaths.IPPath, ">")),
+		CurrentASHopCount: len(asPathFromHops(pathHops)),
+		CurrentIPHopCount: len(utils.HopsByTTL(pathHops)),
+		Hops:              hopResults,
+		PathHops:          pathHops,
+		PreviousPathHops:  previousPathHops,
+		Multipath:         multipathHops,
 	}
 	return traceResult
 }
 
-func PingIP(ctx context.Context, msrID uuid.UUID, target string, count int) error {
-	if count >= icmpTimeout && count <= 100 {
-		icmpTimeout = count
-	} else if count <= icmpTimeout {
-		icmpTimeout = count
-	} else {
-		return fmt.Errorf("requested count: %d is not supported, value should be less than 100 and more than 0", count)
-	}
-	pinger, err := probing.NewPinger(target)
-	pinger.Count = count
-	pinger.Timeout = time.Duration(icmpTimeout) * time.Second
-	pinger.TTL = icmpTTL
-	if err != nil {
-		log.Println("[!] 'PingIP' - Error:", err)
+func PingIP(ctx context.Context, msr models.PingMeasurement) error {
+	msrID, target := msr.ID, msr.Target
+	utils.ApplyPingDefaults(&msr)
+	if err := utils.ValidatePingSettings(msr); err != nil {
 		return err
 	}
-	if err := pinger.RunWithContext(ctx); err != nil {
-		log.Println("[!] 'PingIP' - There has been a problem with sending ICMP packets to the target.")
-		return err
+	var samples []PacketSample
+	if msr.DSCP == 0 {
+		pinger, err := probing.NewPinger(target)
+		if err != nil {
+			log.Println("[!] 'PingIP' - Error:", err)
+			return err
+		}
+		pinger.Count = msr.PacketCount
+		pinger.Size = msr.PacketSize
+		pinger.Interval = time.Duration(msr.Interval) * time.Millisecond
+		pinger.Timeout = time.Duration(msr.Timeout) * time.Second
+		pinger.TTL = msr.TTL
+		pingerSamples := collectSamples(pinger)
+		if err := pinger.RunWithContext(ctx); err != nil {
+			log.Println("[!] 'PingIP' - There has been a problem with sending ICMP packets to the target.")
+			return err
+		}
+		samples = pingerSamples()
+	} else {
+		var err error
+		if samples, err = markedPing(ctx, msr); err != nil {
+			log.Println("[!] 'PingIP' - There has been a problem with sending DSCP marked ICMP packets to the target.", err)
+			return err
+		}
+	}
+	pingResult := newPingResult(samples)
+	traceResult := traceIP(msrID, target, msr.MtrMode, msr.Multipath)
+	pmtuResult := PmtuResult{}
+	if msr.PmtuMode {
+		var err error
+		if pmtuResult.CurrentPathMtu, err = discoverPathMTU(ctx, target); err != nil {
+			log.Println("[!] 'PingIP' - Path MTU discovery failed:", err)
+		}
+		if previous, err := utils.GetLatestPathMtuResult(msrID); err == nil {
+			pmtuResult.PreviousPathMtu = previous.PathMtu
+		}
 	}
-	pingResult := PingResult{
-		Sent:   pinger.Statistics().PacketsSent,
-		Rcvd:   pinger.Statistics().PacketsRecv,
-		Loss:   pinger.Statistics().PacketLoss,
-		AvgRtt: float64(pinger.Statistics().AvgRtt.Milliseconds()),
-		MinRtt: float64(pinger.Statistics().MinRtt.Milliseconds()),
-		MaxRtt: float64(pinger.Statistics().MaxRtt.Milliseconds()),
-		Jitter: float64(pinger.Statistics().StdDevRtt.Milliseconds()),
-	}
-	traceResult := traceIP(msrID, target)
-	if err := saveResult(msrID, target, pingResult, traceResult); err != nil {
+	if err := saveResult(msrID, target, pingResult, traceResult, pmtuResult); err != nil {
 		log.Println("[!] 'PingIP' - Attempt to save measurement results failed.")
 		return err
 	}
@@ -0,0 +1,295 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/utils"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&models.PingMeasurement{},
+		&models.MeasurementResults{},
+		&models.MeasurementResultAlerts{},
+		&models.MeasurementHopResults{},
+		&models.MeasurementPacketSamples{},
+		&models.MeasurementResultPaths{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+}
+
+func TestStorePollKeepsSettings(t *testing.T) {
+	openTestDB(t)
+	tests := []struct {
+		name       string
+		target     string
+		status     int
+		wantStatus int
+	}{
+		{"running", "192.0.2.1", utils.StatusRestarting, utils.StatusRunning},
+		{"stopped during the poll", "192.0.2.2", utils.StatusStopped, utils.StatusStopped},
+	}
+	for _, test := range tests {
+		msr := models.PingMeasurement{ID: uuid.New(), Target: test.target, PacketCount: 10, Frequency: 5, Status: utils.StatusRunning}
+		if err := database.DB.Create(&msr).Error; err != nil {
+			t.Fatal(err)
+		}
+		// The poll works on the row as it was loaded, the user changes it meanwhile
+		polled := msr
+		polled.Results = []models.MeasurementResults{{MsrID: msr.ID, Timestamp: "2024-01-01T00:00:00Z", Sent: 10, Rcvd: 10}}
+		polled.Alerts = []models.MeasurementResultAlerts{{AlertTimestamp: "2024-01-01T00:00:00Z", AlertReason: "LOSS"}}
+		database.DB.Model(&msr).Updates(map[string]any{"frequency": 15, "status": test.status})
+		if err := storePoll(polled); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var stored models.PingMeasurement
+		database.DB.Preload("Results").Preload("Alerts").First(&stored, "id = ?", msr.ID)
+		if stored.Frequency != 15 {
+			t.Errorf("%s: frequency %d was overwritten", test.name, stored.Frequency)
+		}
+		if stored.Status != test.wantStatus {
+			t.Errorf("%s: status %d, want %d", test.name, stored.Status, test.wantStatus)
+		}
+		if len(stored.Results) != 1 || len(stored.Alerts) != 1 || stored.LastPollAt == "" {
+			t.Errorf("%s: poll was not stored, %d results %d alerts", test.name, len(stored.Results), len(stored.Alerts))
+		}
+	}
+}
+
+func TestNewPingResult(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		samples []PacketSample
+		rcvd    int
+		loss    float64
+		jitter  float64
+	}{
+		{"empty", nil, 0, 0, 0},
+		{"all lost", []PacketSample{{Seq: 0}, {Seq: 1}}, 0, 100, 0},
+		{"lost reply is skipped", []PacketSample{{0, true, 10 * ms}, {1, false, 0}, {2, true, 14 * ms}, {3, true, 12 * ms}}, 3, 25, 0.359375},
+	}
+	for _, test := range tests {
+		result := newPingResult(test.samples)
+		if result.Rcvd != test.rcvd || result.Loss != test.loss || result.Jitter != test.jitter {
+			t.Errorf("%s: got %d received %v%% loss %vms jitter", test.name, result.Rcvd, result.Loss, result.Jitter)
+		}
+	}
+}
+
+func TestDiffPaths(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current []string
+		want              PathDiff
+	}{
+		{"empty", nil, nil, PathDiff{}},
+		{"unchanged", []string{"AS1", "AS2"}, []string{"AS1", "AS2"}, PathDiff{}},
+		{"added", []string{"AS1", "AS3"}, []string{"AS1", "AS2", "AS3"}, PathDiff{Added: []HopChange{{Position: 2, Current: "AS2"}}}},
+		{"removed", []string{"AS1", "AS2", "AS3"}, []string{"AS1", "AS3"}, PathDiff{Removed: []HopChange{{Position: 2, Previous: "AS2"}}}},
+		{"changed", []string{"AS1", "AS2", "AS3"}, []string{"AS1", "AS4", "AS3"}, PathDiff{Changed: []HopChange{{Position: 2, Previous: "AS2", Current: "AS4"}}}},
+		{"from nothing", nil, []string{"AS1"}, PathDiff{Added: []HopChange{{Position: 1, Current: "AS1"}}}},
+	}
+	for _, test := range tests {
+		if got := diffPaths(test.previous, test.current); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestASPathFromHops(t *testing.T) {
+	hops := []models.MeasurementPathHops{
+		{TTL: 1, IPAddress: "10.0.0.1", ASN: "Private"},
+		{TTL: 2, IPAddress: "1.1.1.1", ASN: "AS13335"},
+		{TTL: 3, IPAddress: "1.0.0.1", ASN: unknownASN},
+		{TTL: 4, IPAddress: utils.TimeoutHop},
+		{TTL: 5, IPAddress: "8.8.8.8", ASN: "AS15169"},
+	}
+	if got := asPathFromHops(hops); !reflect.DeepEqual(got, []string{"AS13335", "AS15169"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDiffHopsByTTL(t *testing.T) {
+	hop := func(ttl int, ip, asn string) models.MeasurementPathHops {
+		return models.MeasurementPathHops{TTL: ttl, IPAddress: ip, ASN: asn}
+	}
+	base := []models.MeasurementPathHops{hop(1, "1.1.1.1", "AS1"), hop(2, "2.2.2.1", "AS2"), hop(2, "2.2.2.2", "AS2"), hop(3, "3.3.3.3", "AS3")}
+	tests := []struct {
+		name    string
+		current []models.MeasurementPathHops
+		want    PathDiff
+	}{
+		{"unchanged", base, PathDiff{}},
+		{"other ecmp responder", []models.MeasurementPathHops{hop(1, "1.1.1.1", "AS1"), hop(2, "2.2.2.2", "AS2"), hop(2, "2.2.2.3", "AS2"), hop(3, "3.3.3.3", "AS3")}, PathDiff{}},
+		{"router swap inside one as", []models.MeasurementPathHops{hop(1, "1.1.1.1", "AS1"), hop(2, "2.2.2.9", "AS2"), hop(3, "3.3.3.3", "AS3")}, PathDiff{}},
+		{"timeout is no information", []models.MeasurementPathHops{hop(1, "1.1.1.1", "AS1"), hop(2, utils.TimeoutHop, ""), hop(3, "3.3.3.3", "AS3")}, PathDiff{}},
+		{"unknown asn is not the same as", []models.MeasurementPathHops{hop(1, "1.1.1.1", "AS1"), hop(2, "4.4.4.4", unknownASN), hop(3, "3.3.3.3", "AS3")},
+			PathDiff{Changed: []HopChange{{TTL: 2, Previous: "2.2.2.1|2.2.2.2", Current: "4.4.4.4"}}}},
+		{"longer path", append(append([]models.MeasurementPathHops{}, base...), hop(4, "5.5.5.5", "AS5")), PathDiff{Added: []HopChange{{TTL: 4, Current: "5.5.5.5"}}}},
+		{"shorter path", base[:3], PathDiff{Removed: []HopChange{{TTL: 3, Previous: "3.3.3.3"}}}},
+	}
+	for _, test := range tests {
+		if got := diffHopsByTTL(base, test.current); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewParisProbe(t *testing.T) {
+	tests := []struct {
+		name            string
+		id, seq, flowID uint16
+	}{
+		{"first probe", 1, 1, 1},
+		{"later probe", 1, 90, 1},
+		{"other flow", 0x1234, 7, 0xbeef},
+		{"all ones", 0xffff, 0xffff, 0xffff},
+		{"zero identifier", 0, 0, 0x0101},
+	}
+	for _, test := range tests {
+		probe := newParisProbe(test.id, test.seq, test.flowID)
+		// Load balancers see the checksum, it must stay on the flow whatever the sequence number
+		if checksum := binary.BigEndian.Uint16(probe[2:]); checksum != test.flowID {
+			t.Errorf("%s: checksum %#04x, want the flow %#04x", test.name, checksum, test.flowID)
+		}
+		if sum := onesComplementSum(probe); sum != 0xffff {
+			t.Errorf("%s: checksum does not verify, sum %#04x", test.name, sum)
+		}
+		if id, seq := binary.BigEndian.Uint16(probe[4:]), binary.BigEndian.Uint16(probe[6:]); id != test.id || seq != test.seq {
+			t.Errorf("%s: got identifier %d and sequence %d", test.name, id, seq)
+		}
+	}
+}
+
+func TestParseQuotedProbe(t *testing.T) {
+	target := net.IPv4(192, 0, 2, 1).To4()
+	quote := func(id uint16, dst net.IP) []byte {
+		header := make([]byte, 20)
+		header[0] = 0x45
+		binary.BigEndian.PutUint16(header[2:], 32)
+		header[9] = 1
+		copy(header[16:], dst)
+		return append(header, newParisProbe(id, 42, 1)...)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"own probe", quote(7, target), true},
+		{"concurrent trace", quote(8, target), false},
+		{"other target", quote(7, net.IPv4(192, 0, 2, 2).To4()), false},
+		{"truncated", quote(7, target)[:24], false},
+	}
+	for _, test := range tests {
+		seq, fromTarget, ok := parseQuotedProbe(test.data, 7, target)
+		if ok != test.ok || fromTarget || (ok && seq != 42) {
+			t.Errorf("%s: got sequence %d, from target %v, ok %v", test.name, seq, fromTarget, ok)
+		}
+	}
+}
+
+func TestNextICMPID(t *testing.T) {
+	seen := make(map[uint16]bool)
+	for i := 0; i < 100; i++ {
+		id := nextICMPID()
+		if seen[id] {
+			t.Fatalf("identifier %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNewTTLHopResults(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		ipAddrs []string
+		rtts    [][]time.Duration
+		probes  int
+		sent    []int
+		loss    []float64
+	}{
+		{"single responder", []string{"a"}, [][]time.Duration{{ms, ms}}, 10, []int{10}, []float64{80}},
+		{"ecmp without loss", []string{"a", "b"}, [][]time.Duration{{ms, ms, ms}, {ms, ms, ms}}, 6, []int{3, 3}, []float64{0, 0}},
+		{"ecmp with loss", []string{"a", "b"}, [][]time.Duration{{ms, ms, ms}, {ms}}, 10, []int{8, 2}, []float64{62.5, 50}},
+		{"rounding leftovers", []string{"a", "b", "c"}, [][]time.Duration{{ms}, {ms}, {ms}}, 10, []int{4, 3, 3}, []float64{75, float64(2) / 3 * 100, float64(2) / 3 * 100}},
+		{"duplicate replies", []string{"a"}, [][]time.Duration{{ms, ms, ms}}, 2, []int{3}, []float64{0}},
+	}
+	for _, test := range tests {
+		hops := newTTLHopResults(1, test.ipAddrs, test.rtts, test.probes)
+		for i, hop := range hops {
+			if hop.Sent != test.sent[i] || hop.Loss != test.loss[i] {
+				t.Errorf("%s: responder %s sent %d with %v%% loss, want %d with %v%%", test.name, hop.IPAddress, hop.Sent, hop.Loss, test.sent[i], test.loss[i])
+			}
+		}
+	}
+}
+
+func TestFlowICMPID(t *testing.T) {
+	msrID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	flowID := measurementFlowID(msrID)
+	tests := []struct {
+		name      string
+		a, b      uint16
+		wantEqual bool
+	}{
+		{"same flow on every poll", flowICMPID(msrID, flowID), flowICMPID(msrID, flowID), true},
+		{"other flow", flowICMPID(msrID, flowID), flowICMPID(msrID, flowID+2), false},
+		{"other measurement", flowICMPID(msrID, flowID), flowICMPID(uuid.MustParse("6ba7b810-9dae-11d1-80b4-00c04fd430c8"), flowID), false},
+	}
+	for _, test := range tests {
+		if (test.a == test.b) != test.wantEqual {
+			t.Errorf("%s: got identifiers %d and %d", test.name, test.a, test.b)
+		}
+	}
+}
+
+func TestNextICMPSeq(t *testing.T) {
+	seen := make(map[uint16]bool)
+	for i := 0; i < 100; i++ {
+		seq := nextICMPSeq()
+		if seen[seq] {
+			t.Fatalf("sequence number %d handed out twice", seq)
+		}
+		seen[seq] = true
+	}
+}
+
+func TestPmtuHealthCheck(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current int
+		alert             bool
+		details           string
+	}{
+		{"decrease", 1500, 1400, true, `{"previous":1500,"current":1400}`},
+		{"increase", 1400, 1500, false, ""},
+		{"no previous", 0, 1400, false, ""},
+		{"discovery failed", 1500, 0, false, ""},
+	}
+	for _, test := range tests {
+		result := PmtuResult{CurrentPathMtu: test.current, PreviousPathMtu: test.previous}
+		alert, info := result.pmtuHealthCheck()
+		if alert != test.alert || info.AlertDetails != test.details {
+			t.Errorf("%s: got alert %v with details %s", test.name, alert, info.AlertDetails)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package pinger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+// Path MTU
+const (
+	ipv4ICMPHeaderLen = 28
+	pmtuProbeCount    = 2
+	pmtuProbeInterval = 100 * time.Millisecond
+	pmtuProbeTimeout  = time.Second
+)
+
+var pmtuProbeSizes = []int{1500, 1492, 1480, 1472, 1460, 1400, 1380, 1280, 1200, 1000, 576}
+
+type PmtuChange struct {
+	Previous int `json:"previous"`
+	Current  int `json:"current"`
+}
+
+type PmtuResult struct {
+	CurrentPathMtu  int
+	PreviousPathMtu int
+}
+
+func (m *PmtuResult) pmtuHealthCheck() (bool, Alert) {
+	if m.CurrentPathMtu > 0 && m.PreviousPathMtu > 0 && m.CurrentPathMtu < m.PreviousPathMtu {
+		log.Printf("[i] 'pmtuHealthCheck' - Path MTU has decreased from: %d to: %d", m.PreviousPathMtu, m.CurrentPathMtu)
+		details, _ := json.Marshal(PmtuChange{Previous: m.PreviousPathMtu, Current: m.CurrentPathMtu})
+		alert := Alert{
+			AlertTimestamp: time.Now().Format(time.RFC3339),
+			AlertReason:    "PATH_MTU_DECREASE",
+			AlertMessage:   fmt.Sprintf("Path MTU has decreased - current: %d, previous: %d", m.CurrentPathMtu, m.PreviousPathMtu),
+			AlertDetails:   string(details),
+		}
+		return true, alert
+	}
+	return false, Alert{}
+}
+
+func pmtuProbe(ctx context.Context, target string, mtu int) bool {
+	pinger, err := probing.NewPinger(target)
+	if err != nil {
+		return false
+	}
+	pinger.Count = pmtuProbeCount
+	pinger.Size = mtu - ipv4ICMPHeaderLen
+	pinger.Interval = pmtuProbeInterval
+	pinger.Timeout = pmtuProbeTimeout
+	pinger.SetDoNotFragment(true)
+	// Probes larger than the local interface MTU fail to send, which is treated as not fitting
+	if err := pinger.RunWithContext(ctx); err != nil {
+		return false
+	}
+	return pinger.Statistics().PacketsRecv > 0
+}
+
+func discoverPathMTU(ctx context.Context, target string) (int, error) {
+	tooLarge := 0
+	for _, size := range pmtuProbeSizes {
+		if !pmtuProbe(ctx, target, size) {
+			tooLarge = size
+			continue
+		}
+		if tooLarge == 0 {
+			return size, nil
+		}
+		// Narrow down between the largest probe that fitted and the smallest one that did not
+		low, high := size, tooLarge
+		for high-low > 1 {
+			mid := (low + high) / 2
+			if pmtuProbe(ctx, target, mid) {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		return low, nil
+	}
+	return 0, errors.Errorf("None of the DF probes reached target: %s", target)
+}
@@ -0,0 +1,36 @@
+package pinger
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sngx13/pingernoid/utils"
+)
+
+func evaluateAlertRules(msrID uuid.UUID) []Alert {
+	var alerts []Alert
+	rules, err := utils.GetAlertRules(msrID)
+	if err != nil {
+		log.Println("[!] 'evaluateAlertRules' - Could not load alert rules:", err)
+		return alerts
+	}
+	for _, rule := range rules {
+		value, triggered, err := utils.EvaluateAlertRule(rule)
+		if err != nil {
+			log.Printf("[!] 'evaluateAlertRules' - Could not evaluate rule: %d, %v", rule.ID, err)
+			continue
+		}
+		if !triggered {
+			continue
+		}
+		log.Printf("[i] 'evaluateAlertRules' - Rule: %d triggered, %s: %.3f %s %.3f", rule.ID, rule.Metric, value, rule.Operator, rule.Threshold)
+		alerts = append(alerts, Alert{
+			AlertTimestamp: time.Now().Format(time.RFC3339),
+			AlertReason:    "ALERT_RULE",
+			AlertMessage:   fmt.Sprintf("Rule #%d - %s over the last %d minutes: %.3f (%s %.3f)", rule.ID, rule.Metric, rule.Window, value, rule.Operator, rule.Threshold),
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,76 @@
+package pinger
+
+import (
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+	"github.com/sngx13/pingernoid/utils"
+)
+
+type PacketSample struct {
+	Seq      int
+	Received bool
+	Rtt      time.Duration
+}
+
+func rttMilliseconds(rtt time.Duration) float64 {
+	// Microsecond precision, LAN and metro RTTs are well below a millisecond
+	return float64(rtt.Microseconds()) / 1000
+}
+
+func collectSamples(pinger *probing.Pinger) func() []PacketSample {
+	rtts := make(map[int]time.Duration)
+	pinger.OnRecv = func(pkt *probing.Packet) {
+		rtts[pkt.Seq] = pkt.Rtt
+	}
+	return func() []PacketSample {
+		samples := make([]PacketSample, pinger.PacketsSent)
+		for seq := range samples {
+			samples[seq].Seq = seq
+			samples[seq].Rtt, samples[seq].Received = rtts[seq]
+		}
+		return samples
+	}
+}
+
+func sampleJitter(samples []PacketSample) float64 {
+	var rtts []float64
+	for _, sample := range samples {
+		if sample.Received {
+			rtts = append(rtts, rttMilliseconds(sample.Rtt))
+		}
+	}
+	return utils.PollJitter([][]float64{rtts})
+}
+
+func newPingResult(samples []PacketSample) PingResult {
+	pingResult := PingResult{Sent: len(samples), Samples: samples}
+	var total, min, max time.Duration
+	for _, sample := range samples {
+		if !sample.Received {
+			continue
+		}
+		if pingResult.Rcvd == 0 || sample.Rtt < min {
+			min = sample.Rtt
+		}
+		if sample.Rtt > max {
+			max = sample.Rtt
+		}
+		total += sample.Rtt
+		pingResult.Rcvd++
+	}
+	if pingResult.Sent > 0 {
+		pingResult.Loss = float64(pingResult.Sent-pingResult.Rcvd) / float64(pingResult.Sent) * 100
+	}
+	if pingResult.Rcvd == 0 {
+		pingResult.RFactor, pingResult.MOS = utils.VoiceQuality(0, 0, pingResult.Loss)
+		return pingResult
+	}
+	pingResult.AvgRtt = rttMilliseconds(total / time.Duration(pingResult.Rcvd))
+	pingResult.MinRtt = rttMilliseconds(min)
+	pingResult.MaxRtt = rttMilliseconds(max)
+	pingResult.Jitter = sampleJitter(samples)
+	// The E-model expects one-way delay
+	pingResult.RFactor, pingResult.MOS = utils.VoiceQuality(pingResult.AvgRtt/2, pingResult.Jitter, pingResult.Loss)
+	return pingResult
+}
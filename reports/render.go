@@ -0,0 +1,52 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"strconv"
+)
+
+// Report rendering
+const (
+	emailTemplatePath = "templates/reports/report_email.html"
+)
+
+func RenderHTML(name string, report Report) (string, error) {
+	tmpl, err := template.New("report_email.html").Delims("{[{", "}]}").ParseFiles(emailTemplatePath)
+	if err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]any{"Name": name, "Report": report}); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+func RenderCSV(report Report) (string, error) {
+	var body bytes.Buffer
+	writer := csv.NewWriter(&body)
+	writer.Write([]string{"msr_id", "target", "from", "to", "polls", "availability", "mean_rtt", "loss", "incident_count", "incident_minutes", "sla_breach_minutes"})
+	for _, msr := range report.Measurements {
+		writer.Write([]string{
+			msr.MsrID,
+			msr.Target,
+			report.From,
+			report.To,
+			strconv.Itoa(msr.Polls),
+			fmt.Sprintf("%.3f", msr.Availability),
+			fmt.Sprintf("%.3f", msr.MeanRtt),
+			fmt.Sprintf("%.3f", msr.Loss),
+			strconv.Itoa(msr.IncidentCount),
+			strconv.Itoa(msr.IncidentMinutes),
+			strconv.Itoa(msr.SlaBreachMinutes),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
@@ -0,0 +1,188 @@
+package reports
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Report periods
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// SLA defaults, in line with the health check thresholds
+const (
+	DefaultSlaMaxAvgRtt = 100
+	DefaultSlaMaxLoss   = 1
+)
+
+type SLA struct {
+	MaxAvgRtt float64 `json:"max_avg_rtt"`
+	MaxLoss   float64 `json:"max_loss"`
+}
+
+type Incident struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Duration int    `json:"duration"`
+	Polls    int    `json:"polls"`
+}
+
+type MeasurementReport struct {
+	MsrID            string     `json:"msr_id"`
+	Target           string     `json:"target"`
+	Polls            int        `json:"polls"`
+	AvailablePolls   int        `json:"available_polls"`
+	Availability     float64    `json:"availability"`
+	MeanRtt          float64    `json:"mean_rtt"`
+	Loss             float64    `json:"loss"`
+	IncidentCount    int        `json:"incident_count"`
+	IncidentMinutes  int        `json:"incident_minutes"`
+	SlaBreachMinutes int        `json:"sla_breach_minutes"`
+	Incidents        []Incident `json:"incidents"`
+	sent, rcvd       int
+	rttTotal         float64
+}
+
+type Report struct {
+	Period           string              `json:"period"`
+	From             string              `json:"from"`
+	To               string              `json:"to"`
+	GeneratedAt      string              `json:"generated_at"`
+	SLA              SLA                 `json:"sla"`
+	Availability     float64             `json:"availability"`
+	MeanRtt          float64             `json:"mean_rtt"`
+	Loss             float64             `json:"loss"`
+	IncidentCount    int                 `json:"incident_count"`
+	IncidentMinutes  int                 `json:"incident_minutes"`
+	SlaBreachMinutes int                 `json:"sla_breach_minutes"`
+	Measurements     []MeasurementReport `json:"measurements"`
+}
+
+func PeriodWindow(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case PeriodDay:
+		return now.AddDate(0, 0, -1), nil
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), nil
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0), nil
+	}
+	return now, errors.Errorf("Period: '%s' is not supported, use one of day, week, month.", period)
+}
+
+func closeIncident(report *MeasurementReport, incident *Incident, frequency int) {
+	// A poll stands for the minutes until the next one
+	start, _ := time.Parse(time.RFC3339, incident.Start)
+	end, _ := time.Parse(time.RFC3339, incident.End)
+	incident.Duration = int(end.Sub(start).Minutes()) + frequency
+	report.Incidents = append(report.Incidents, *incident)
+	report.IncidentCount++
+	report.IncidentMinutes += incident.Duration
+}
+
+func measurementReport(msr models.PingMeasurement, from, to string, sla SLA) (MeasurementReport, error) {
+	report := MeasurementReport{MsrID: msr.ID.String(), Target: msr.Target, Incidents: []Incident{}}
+	var results []models.MeasurementResults
+	if err := database.DB.Where("msr_id = ? AND timestamp >= ? AND timestamp <= ?", msr.ID, from, to).
+		Order("timestamp").Find(&results).Error; err != nil {
+		return report, err
+	}
+	var incident *Incident
+	for _, result := range results {
+		report.Polls++
+		report.sent += result.Sent
+		report.rcvd += result.Rcvd
+		report.rttTotal += result.AvgRtt * float64(result.Rcvd)
+		if result.Rcvd == 0 {
+			// Consecutive polls without a single reply form one incident
+			if incident == nil {
+				incident = &Incident{Start: result.Timestamp}
+			}
+			incident.End = result.Timestamp
+			incident.Polls++
+			report.SlaBreachMinutes += msr.Frequency
+			continue
+		}
+		if incident != nil {
+			closeIncident(&report, incident, msr.Frequency)
+			incident = nil
+		}
+		report.AvailablePolls++
+		if result.Loss > sla.MaxLoss || result.AvgRtt > sla.MaxAvgRtt {
+			report.SlaBreachMinutes += msr.Frequency
+		}
+	}
+	if incident != nil {
+		closeIncident(&report, incident, msr.Frequency)
+	}
+	if report.Polls > 0 {
+		report.Availability = float64(report.AvailablePolls) / float64(report.Polls) * 100
+	}
+	if report.sent > 0 {
+		report.Loss = float64(report.sent-report.rcvd) / float64(report.sent) * 100
+	}
+	if report.rcvd > 0 {
+		report.MeanRtt = report.rttTotal / float64(report.rcvd)
+	}
+	return report, nil
+}
+
+func GenerateReport(msrIDs []string, period string, sla SLA) (Report, error) {
+	now := time.Now()
+	report := Report{
+		Period:       period,
+		To:           now.Format(time.RFC3339),
+		GeneratedAt:  now.Format(time.RFC3339),
+		SLA:          sla,
+		Measurements: []MeasurementReport{},
+	}
+	from, err := PeriodWindow(period, now)
+	if err != nil {
+		return report, err
+	}
+	report.From = from.Format(time.RFC3339)
+	var msrs []models.PingMeasurement
+	query := database.DB.Order("created_at")
+	if len(msrIDs) > 0 {
+		query = query.Where("id IN ?", msrIDs)
+	}
+	if err := query.Find(&msrs).Error; err != nil {
+		return report, err
+	}
+	if len(msrs) == 0 {
+		return report, errors.New("No measurements found for the report.")
+	}
+	var polls, availablePolls, sent, rcvd int
+	var rttTotal float64
+	for _, msr := range msrs {
+		msrReport, err := measurementReport(msr, report.From, report.To, sla)
+		if err != nil {
+			return report, err
+		}
+		polls += msrReport.Polls
+		availablePolls += msrReport.AvailablePolls
+		sent += msrReport.sent
+		rcvd += msrReport.rcvd
+		rttTotal += msrReport.rttTotal
+		report.IncidentCount += msrReport.IncidentCount
+		report.IncidentMinutes += msrReport.IncidentMinutes
+		report.SlaBreachMinutes += msrReport.SlaBreachMinutes
+		report.Measurements = append(report.Measurements, msrReport)
+	}
+	if polls > 0 {
+		report.Availability = float64(availablePolls) / float64(polls) * 100
+	}
+	if sent > 0 {
+		report.Loss = float64(sent-rcvd) / float64(sent) * 100
+	}
+	if rcvd > 0 {
+		report.MeanRtt = rttTotal / float64(rcvd)
+	}
+	return report, nil
+}
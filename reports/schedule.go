@@ -0,0 +1,222 @@
+package reports
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/notifier"
+)
+
+// Delivery channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+type webhookPayload struct {
+	Name   string `json:"name"`
+	Report Report `json:"report"`
+	HTML   string `json:"html"`
+	CSV    string `json:"csv"`
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func nextRun(period string, from time.Time) time.Time {
+	if period == PeriodMonth {
+		return from.AddDate(0, 1, 0)
+	}
+	return from.AddDate(0, 0, 7)
+}
+
+func ValidateReportSchedule(schedule models.ReportSchedule) error {
+	if schedule.Name == "" {
+		return errors.New("Please specify a name for the report.")
+	}
+	if strings.ContainsAny(schedule.Name, "\r\n") {
+		return errors.New("Report name must be a single line.")
+	}
+	if schedule.Period != PeriodWeek && schedule.Period != PeriodMonth {
+		return errors.Errorf("Period: '%s' is not supported, use one of week, month.", schedule.Period)
+	}
+	switch schedule.Channel {
+	case ChannelEmail:
+		for _, address := range splitList(schedule.Destination) {
+			if !strings.Contains(address, "@") {
+				return errors.Errorf("Email address: '%s' is not valid.", address)
+			}
+		}
+		if len(splitList(schedule.Destination)) == 0 {
+			return errors.New("Please specify at least one email address.")
+		}
+	case ChannelWebhook:
+		target, err := url.Parse(schedule.Destination)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return errors.Errorf("Webhook URL: '%s' is not valid.", schedule.Destination)
+		}
+	default:
+		return errors.Errorf("Channel: '%s' is not supported, use one of email, webhook.", schedule.Channel)
+	}
+	return nil
+}
+
+func AddReportSchedule(schedule models.ReportSchedule) (models.ReportSchedule, error) {
+	if schedule.SlaMaxAvgRtt == 0 {
+		schedule.SlaMaxAvgRtt = DefaultSlaMaxAvgRtt
+	}
+	if schedule.SlaMaxLoss == 0 {
+		schedule.SlaMaxLoss = DefaultSlaMaxLoss
+	}
+	schedule.MsrIDs = strings.Join(splitList(schedule.MsrIDs), ",")
+	if err := ValidateReportSchedule(schedule); err != nil {
+		return schedule, err
+	}
+	now := time.Now()
+	schedule.CreatedAt = now.Format(time.RFC3339)
+	schedule.LastRunAt = "Never"
+	schedule.NextRunAt = nextRun(schedule.Period, now).Format(time.RFC3339)
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		return schedule, errors.Wrap(err, "Problem saving report schedule to database.")
+	}
+	return schedule, nil
+}
+
+func GetReportSchedules(ownerID uint) ([]models.ReportSchedule, error) {
+	var schedules []models.ReportSchedule
+	query := database.DB.Order("id")
+	if ownerID != 0 {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+	if err := query.Find(&schedules).Error; err != nil {
+		return schedules, err
+	}
+	return schedules, nil
+}
+
+func GetReportSchedule(scheduleID int) (models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	if err := database.DB.First(&schedule, "id = ?", scheduleID).Error; err != nil {
+		return schedule, err
+	}
+	return schedule, nil
+}
+
+func DeleteReportSchedule(scheduleID int) error {
+	result := database.DB.Where("id = ?", scheduleID).Delete(&models.ReportSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.Errorf("Report schedule: %d not found.", scheduleID)
+	}
+	return nil
+}
+
+func GetGeneratedReports(scheduleID int, ownerID uint) ([]models.GeneratedReport, error) {
+	var generated []models.GeneratedReport
+	query := database.DB.Omit("html", "csv").Order("id desc")
+	if scheduleID > 0 {
+		query = query.Where("schedule_id = ?", scheduleID)
+	}
+	if ownerID != 0 {
+		query = query.Where("schedule_id IN (?)", database.DB.Model(&models.ReportSchedule{}).Select("id").Where("owner_id = ?", ownerID))
+	}
+	if err := query.Find(&generated).Error; err != nil {
+		return generated, err
+	}
+	return generated, nil
+}
+
+func GetGeneratedReport(reportID int) (models.GeneratedReport, error) {
+	var generated models.GeneratedReport
+	if err := database.DB.First(&generated, "id = ?", reportID).Error; err != nil {
+		return generated, err
+	}
+	return generated, nil
+}
+
+func deliver(schedule models.ReportSchedule, report Report, html, csv string) error {
+	switch schedule.Channel {
+	case ChannelEmail:
+		subject := fmt.Sprintf("Pingernoid report: %s (%s)", schedule.Name, schedule.Period)
+		attachment := notifier.Attachment{
+			Filename:    fmt.Sprintf("report-%s.csv", time.Now().Format("2006-01-02")),
+			ContentType: "text/csv",
+			Content:     []byte(csv),
+		}
+		return notifier.SendEmail(splitList(schedule.Destination), subject, html, []notifier.Attachment{attachment})
+	case ChannelWebhook:
+		return notifier.SendWebhook(schedule.Destination, webhookPayload{Name: schedule.Name, Report: report, HTML: html, CSV: csv})
+	}
+	return errors.Errorf("Channel: '%s' is not supported.", schedule.Channel)
+}
+
+func RunReportSchedule(schedule models.ReportSchedule) (models.GeneratedReport, error) {
+	sla := SLA{MaxAvgRtt: schedule.SlaMaxAvgRtt, MaxLoss: schedule.SlaMaxLoss}
+	report, err := GenerateReport(splitList(schedule.MsrIDs), schedule.Period, sla)
+	if err != nil {
+		return models.GeneratedReport{}, err
+	}
+	html, err := RenderHTML(schedule.Name, report)
+	if err != nil {
+		return models.GeneratedReport{}, errors.Wrap(err, "Could not render report.")
+	}
+	csv, err := RenderCSV(report)
+	if err != nil {
+		return models.GeneratedReport{}, errors.Wrap(err, "Could not render report.")
+	}
+	generated := models.GeneratedReport{
+		ScheduleID:  schedule.ID,
+		GeneratedAt: report.GeneratedAt,
+		Period:      report.Period,
+		From:        report.From,
+		To:          report.To,
+		HTML:        html,
+		CSV:         csv,
+		Delivered:   true,
+	}
+	// A failed delivery is kept in the history so it can still be downloaded
+	if err := deliver(schedule, report, html, csv); err != nil {
+		log.Printf("[!] 'RunReportSchedule' - Could not deliver report: %d, %v", schedule.ID, err)
+		generated.Delivered = false
+		generated.DeliveryError = err.Error()
+	}
+	if err := database.DB.Create(&generated).Error; err != nil {
+		return generated, errors.Wrap(err, "Problem saving generated report to database.")
+	}
+	return generated, nil
+}
+
+func RunDueReportSchedules() {
+	var schedules []models.ReportSchedule
+	now := time.Now()
+	if err := database.DB.Where("next_run_at <= ?", now.Format(time.RFC3339)).Find(&schedules).Error; err != nil {
+		log.Println("[!] 'RunDueReportSchedules' - Error querying database:", err)
+		return
+	}
+	for _, schedule := range schedules {
+		log.Printf("[i] 'RunDueReportSchedules' - Generating report: %d (%s)", schedule.ID, schedule.Name)
+		if _, err := RunReportSchedule(schedule); err != nil {
+			log.Printf("[!] 'RunDueReportSchedules' - Could not generate report: %d, %v", schedule.ID, err)
+		}
+		schedule.LastRunAt = now.Format(time.RFC3339)
+		schedule.NextRunAt = nextRun(schedule.Period, now).Format(time.RFC3339)
+		if err := database.DB.Save(&schedule).Error; err != nil {
+			log.Printf("[!] 'RunDueReportSchedules' - Could not update schedule: %d, %v", schedule.ID, err)
+		}
+	}
+}
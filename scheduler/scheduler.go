@@ -3,6 +3,7 @@ package scheduler
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
@@ -10,9 +11,16 @@ import (
 	"github.com/sngx13/pingernoid/database"
 	"github.com/sngx13/pingernoid/models"
 	"github.com/sngx13/pingernoid/pinger"
+	"github.com/sngx13/pingernoid/reports"
 	"github.com/sngx13/pingernoid/utils"
 )
 
+// Running ping jobs, so a measurement can be rescheduled or removed
+var (
+	pingJobsMu sync.Mutex
+	pingJobs   = map[uuid.UUID]*gocron.Scheduler{}
+)
+
 func SchedulePingMeasurement(msrID uuid.UUID, target string, count, frequency int) {
 	log.Printf("[*] 'SchedulePingMeasurement' - Adding measurement: %s to scheduler...", msrID.String())
 	s := gocron.NewScheduler(time.UTC)
@@ -23,14 +31,32 @@ func SchedulePingMeasurement(msrID uuid.UUID, target string, count, frequency in
 		if err := database.DB.First(&msr, "id = ?", msrID).Error; err != nil {
 			log.Printf("[!] 'SchedulePingMeasurement' - Error querying database: %v, could not find measurement: %s", err, msrID.String())
 		}
-		if msr.Status > utils.StatusStopped && msr.ID != uuid.Nil {
+		if denied, ok := utils.DeniedPrefixFor(msr.Target); ok {
+			log.Printf("[!] 'SchedulePingMeasurement' - Skipping measurement: %s as target: %s is within denied prefix: %s.", msr.ID.String(), msr.Target, denied.Prefix)
+		} else if msr.Status > utils.StatusStopped && msr.ID != uuid.Nil {
 			log.Printf("[i] 'SchedulePingMeasurement' - Measurement: %s is 'RUNNING', performing ICMP test towards: %s", msr.ID.String(), msr.Target)
-			pinger.PingIP(context.Background(), msr.ID, msr.Target, msr.PacketCount)
+			pinger.PingIP(context.Background(), msr)
 		} else {
 			log.Printf("[i] 'SchedulePingMeasurement' - Skipping measurement: %s as it is in 'STOPPED' state.", msr.ID.String())
 		}
 	})
 	s.StartAsync()
+	pingJobsMu.Lock()
+	defer pingJobsMu.Unlock()
+	if previous, ok := pingJobs[msrID]; ok {
+		previous.Stop()
+	}
+	pingJobs[msrID] = s
+}
+
+func UnschedulePingMeasurement(msrID uuid.UUID) {
+	pingJobsMu.Lock()
+	defer pingJobsMu.Unlock()
+	if s, ok := pingJobs[msrID]; ok {
+		log.Printf("[*] 'UnschedulePingMeasurement' - Removing measurement: %s from scheduler...", msrID.String())
+		s.Stop()
+		delete(pingJobs, msrID)
+	}
 }
 
 func SchedulerHouseKeeping() {
@@ -45,3 +71,12 @@ func SchedulerHouseKeeping() {
 		}
 	}
 }
+
+func ScheduleReports() {
+	log.Println("[*] 'ScheduleReports' - Adding report delivery to scheduler...")
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(15).Minutes().Do(func() {
+		reports.RunDueReportSchedules()
+	})
+	s.StartAsync()
+}
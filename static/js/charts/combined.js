@@ -35,11 +35,11 @@ function drawCharts(msrID, timeRange) {
         },
         yaxis: {
             type: "numeric",
-            decimalsInFloat: 0,
+            decimalsInFloat: 3,
             title: { text: "Milliseconds" },
             labels: {
                 formatter: function (value) {
-                    return value.toFixed(0) + "ms";
+                    return (value < 10 ? value.toFixed(3) : value.toFixed(1)) + "ms";
                 }
             },
             style: {
@@ -240,6 +240,51 @@ function drawCharts(msrID, timeRange) {
         hopChartOptions
     );
     msrHopChart.render();
+    // Path MTU Chart
+    var msrMtuChart = null;
+    if (document.querySelector("#measurement_mtu_chart")) {
+        var mtuChartOptions = $.extend(true, {}, rttChartOptions, {
+            chart: {
+                type: "line",
+            },
+            stroke: {
+                curve: "stepline"
+            },
+            yaxis: {
+                decimalsInFloat: 0,
+                title: { text: "Bytes" },
+                labels: {
+                    formatter: function (value) {
+                        return value.toFixed(0) + "B";
+                    }
+                }
+            }
+        });
+        msrMtuChart = new ApexCharts(
+            document.querySelector("#measurement_mtu_chart"),
+            mtuChartOptions
+        );
+        msrMtuChart.render();
+    }
+    // Voice Quality Chart
+    var voiceChartOptions = $.extend(true, {}, rttChartOptions, {
+        yaxis: {
+            min: 1,
+            max: 5,
+            decimalsInFloat: 2,
+            title: { text: "MOS" },
+            labels: {
+                formatter: function (value) {
+                    return value.toFixed(2);
+                }
+            }
+        }
+    });
+    var msrVoiceChart = new ApexCharts(
+        document.querySelector("#measurement_voice_chart"),
+        voiceChartOptions
+    );
+    msrVoiceChart.render();
     // Get chart data
     var url = "/api/v1/measurements/" + msrID + "/results/combined/" + timeRange;
     $.getJSON(url, function (response) {
@@ -258,5 +303,11 @@ function drawCharts(msrID, timeRange) {
         var ipHopCountData = response.data.Hop.IPHopCount;
         var asHopCountData = response.data.Hop.ASHopCount;
         msrHopChart.updateSeries([ipHopCountData, asHopCountData]);
+        // Voice Quality Statistics
+        msrVoiceChart.updateSeries([response.data.Voice.MOS]);
+        // Path MTU Statistics
+        if (msrMtuChart) {
+            msrMtuChart.updateSeries([response.data.Mtu.PathMtu]);
+        }
     });
 };
\ No newline at end of file
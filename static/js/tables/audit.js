@@ -0,0 +1,68 @@
+function auditLogURL() {
+    let params = new URLSearchParams();
+    $("#audit_filters").serializeArray().forEach(function (field) {
+        if (field.value) {
+            params.append(field.name, field.value);
+        }
+    });
+    return "/api/v1/audit?" + params.toString();
+}
+
+$(document).ready(function () {
+    var auditTable = $("#audit_logs").DataTable({
+        "dom": '<"top"B>rt<"bottom"iflp><"clear">',
+        "responsive": true,
+        "lengthMenu": [25, 50, 100],
+        "order": [],
+        "processing": true,
+        "ajax": {
+            "url": auditLogURL(),
+            "datatype": "json",
+            "contentType": "application/json; charset=utf-8",
+            "cache": "false",
+        },
+        "columns": [
+            { "data": "timestamp" },
+            {
+                "data": null,
+                render: function (data, type, row, meta) {
+                    let actor = $.fn.dataTable.render.text().display(data.username || "system");
+                    if (data.token_prefix) {
+                        actor += ` <span class="badge bg-secondary">${data.token_prefix}</span>`;
+                    }
+                    return actor;
+                }
+            },
+            { "data": "source_ip" },
+            {
+                "data": "action",
+                render: function (data, type, row, meta) {
+                    let badgeClass = "";
+                    switch (data) {
+                        case "create":
+                            badgeClass = "bg-success";
+                            break;
+                        case "delete":
+                        case "revoke":
+                            badgeClass = "bg-danger";
+                            break;
+                        case "stop":
+                            badgeClass = "bg-dark";
+                            break;
+                        default:
+                            badgeClass = "bg-info";
+                    }
+                    return `<span class="badge ${badgeClass}">${data}</span>`;
+                }
+            },
+            { "data": "object_type" },
+            { "data": "object_id", render: $.fn.dataTable.render.text() },
+            { "data": "before", "className": "text-break small", render: $.fn.dataTable.render.text() },
+            { "data": "after", "className": "text-break small", render: $.fn.dataTable.render.text() },
+        ],
+    });
+    $("#audit_filters").on("submit", function (evt) {
+        evt.preventDefault();
+        auditTable.ajax.url(auditLogURL()).load();
+    });
+});
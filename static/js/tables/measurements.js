@@ -1,14 +1,37 @@
+// Follow the cursor until every visible measurement has been loaded
+async function loadMeasurements() {
+    let rows = [];
+    let cursor = "";
+    do {
+        let params = new URLSearchParams({ "limit": 500 });
+        $("#measurement_filters").serializeArray().forEach(function (field) {
+            if (field.value) {
+                params.append(field.name, field.value);
+            }
+        });
+        if (cursor) {
+            params.append("cursor", cursor);
+        }
+        let response = await $.getJSON("/api/v1/measurements?" + params.toString());
+        if (response.status != 200) {
+            break;
+        }
+        rows = rows.concat(response.data);
+        cursor = response.next_cursor;
+    } while (cursor);
+    return rows;
+}
+
 $(document).ready(function () {
     var msrTable = $("#measurements").DataTable({
         "dom": '<"top"B>rt<"bottom"iflp><"clear">',
         "responsive": true,
         "lengthMenu": [10, 15, 25, 50],
         "processing": true,
-        "ajax": {
-            "url": "/api/v1/measurements",
-            "datatype": "json",
-            "contentType": "application/json; charset=utf-8",
-            "cache": "false",
+        "ajax": function (data, callback, settings) {
+            loadMeasurements().then(function (rows) {
+                callback({ "data": rows });
+            });
         },
         "columns": [
             {
@@ -19,7 +42,13 @@ $(document).ready(function () {
             },
             { "data": "created_at" },
             { "data": "last_poll_at" },
-            { "data": "target" },
+            {
+                "data": null,
+                render: function (data, type, row, meta) {
+                    let tags = data.tags.map(tag => `<span class="badge bg-secondary">${tag}</span>`).join(" ");
+                    return `${data.target} ${tags}`;
+                }
+            },
             { "data": "packet_count" },
             { "data": "frequency" },
             {
@@ -46,24 +75,14 @@ $(document).ready(function () {
                     return `<span class="badge ${badgeClass}">${data}</span>`;
                 }
             },
+            { "data": "result_count" },
             {
-                "data": null,
-                render: function (data, type, row, meta) {
-                    if (typeof data.results === 'string' || Array.isArray(data.results)) {
-                        return data.results.length;
-                    } else {
-                        return 0;
-                    }
-                }
-            },
-            {
-                "data": null,
+                "data": "open_alert_count",
                 render: function (data, type, row, meta) {
-                    if (typeof data.alerts === 'string' || Array.isArray(data.alerts)) {
-                        return data.alerts.length;
-                    } else {
-                        return 0;
+                    if (row.alerting) {
+                        return `<span class="badge bg-danger">${data}</span>`;
                     }
+                    return data;
                 }
             },
             {
@@ -88,6 +107,11 @@ $(document).ready(function () {
             htmx.process("#measurements");
         },
     });
+    $("#measurement_filters").on("change", function (evt) {
+        msrTable.ajax.reload(function () {
+            htmx.process("#measurements");
+        });
+    });
     document.body.addEventListener("reloadTable", function (evt) {
         msrTable.ajax.reload(function () {
             setTimeout(htmx.process("#measurements"), 2000);
@@ -1,81 +1,105 @@
-function drawTracePath(msrID) {
-    var url = "/api/v1/measurements/" + msrID + "/traceroute/path";
-    $.getJSON(url, function (response) {
-        var container = document.getElementById("tracePath");
-        var data = {
-            nodes: response.data.nodes,
-            edges: response.data.edges
-        };
-        var options = {
-            manipulation: false,
-            autoResize: true,
-            height: "100%",
-            width: "100%",
-            locale: "en",
-            layout: {
-                improvedLayout: true,
-                hierarchical: {
-                    enabled: false,
+function tracePathOptions() {
+    return {
+        manipulation: false,
+        autoResize: true,
+        height: "100%",
+        width: "100%",
+        locale: "en",
+        layout: {
+            improvedLayout: true,
+            hierarchical: {
+                enabled: false,
+            }
+        },
+        edges: {
+            arrows: {
+                to: {
+                    enabled: true,
+                    type: "arrow",
                 }
             },
-            edges: {
-                arrows: {
-                    to: {
-                        enabled: true,
-                        type: "arrow",
-                    }
+            color: {
+                color: "#eb1313",
+                highlight: "#35c90c",
+                hover: "#35c90c"
+            }
+        },
+        nodes: {
+            shape: "box",
+            size: 16,
+            color: {
+                border: "#000000",
+                background: "#2b2e2b",
+                highlight: {
+                    border: "#35c90c",
+                    background: "#2b2e2b"
                 },
-                color: {
-                    color: "#eb1313",
-                    highlight: "#35c90c",
-                    hover: "#35c90c"
+                hover: {
+                    border: "#35c90c",
+                    background: "#2b2e2b"
                 }
             },
-            nodes: {
-                shape: "box",
-                size: 16,
-                color: {
-                    border: "#000000",
-                    background: "#2b2e2b",
-                    highlight: {
-                        border: "#35c90c",
-                        background: "#2b2e2b"
-                    },
-                    hover: {
-                        border: "#35c90c",
-                        background: "#2b2e2b"
-                    }
-                },
-                font: {
-                    size: 10,
-                    face: "arial",
-                    color: "#ffffff"
-                },
+            font: {
+                size: 10,
+                face: "arial",
+                color: "#ffffff"
             },
-            physics: {
+        },
+        physics: {
+            enabled: true,
+            forceAtlas2Based: {
+                theta: 0.5,
+                gravitationalConstant: -50,
+                centralGravity: 0.01,
+                springConstant: 0.08,
+                springLength: 100,
+                damping: 0.4,
+                avoidOverlap: 0
+            },
+            stabilization: {
                 enabled: true,
-                forceAtlas2Based: {
-                    theta: 0.5,
-                    gravitationalConstant: -50,
-                    centralGravity: 0.01,
-                    springConstant: 0.08,
-                    springLength: 100,
-                    damping: 0.4,
-                    avoidOverlap: 0
-                },
-                stabilization: {
-                    enabled: true,
-                    iterations: 1000,
-                    updateInterval: 100,
-                    onlyDynamicEdges: false,
-                    fit: true
-                },
-                solver: "forceAtlas2Based",
-            }
+                iterations: 1000,
+                updateInterval: 100,
+                onlyDynamicEdges: false,
+                fit: true
+            },
+            solver: "forceAtlas2Based",
+        }
+    };
+};
+
+function renderTracePath(response, options) {
+    var container = document.getElementById("tracePath");
+    var data = {
+        nodes: response.data.nodes,
+        edges: response.data.edges
+    };
+    var network = new vis.Network(container, data, options);
+    network.on("stabilized", function (params) {
+        network.fit({ animation: { duration: 1000, easingFunction: "easeInOutQuad" } });
+    });
+};
+
+function drawTracePath(msrID) {
+    var url = "/api/v1/measurements/" + msrID + "/traceroute/path";
+    $.getJSON(url, function (response) {
+        renderTracePath(response, tracePathOptions());
+    });
+};
+
+function drawTopology(msrID, timeRange) {
+    // Every path seen in the time range merged into one graph, edge width shows how often a transition was taken
+    var url = "/api/v1/measurements/" + msrID + "/traceroute/topology/" + timeRange;
+    $.getJSON(url, function (response) {
+        var options = tracePathOptions();
+        options.edges.scaling = {
+            min: 1,
+            max: 8
+        };
+        options.edges.font = {
+            size: 8,
+            align: "top"
         };
-        var network = new vis.Network(container, data, options);
-        network.on("stabilized", function (params) {
-            network.fit({ animation: { duration: 1000, easingFunction: "easeInOutQuad" } });
-        });
+        renderTracePath(response, options);
     });
 };
\ No newline at end of file
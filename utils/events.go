@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Measurement timeline events
+const (
+	EventCreated   = "created"
+	EventStopped   = "stopped"
+	EventRestarted = "restarted"
+	EventUpdated   = "updated"
+)
+
+// Settings which can be changed on an existing measurement, by column name
+var msrSettingColumns = []string{
+	"packet_count", "frequency", "packet_size", "interval", "ttl", "timeout", "dscp",
+	"mtr_mode", "multipath", "pmtu_mode",
+	"threshold_min_rtt", "threshold_max_rtt", "threshold_avg_rtt", "threshold_jitter", "threshold_loss",
+	"tags",
+}
+
+type SettingChange struct {
+	Setting string `json:"setting"`
+	From    any    `json:"from"`
+	To      any    `json:"to"`
+}
+
+func AddMeasurementEvent(msrID uuid.UUID, kind, username, message string, changes []SettingChange) {
+	event := models.MeasurementEvent{
+		MsrID:     msrID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Kind:      kind,
+		Username:  username,
+		Message:   message,
+	}
+	if len(changes) > 0 {
+		encoded, _ := json.Marshal(changes)
+		event.Changes = string(encoded)
+	}
+	// The timeline is informational, a failed write should not fail the change itself
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("[!] 'AddMeasurementEvent' - Could not record %s event for measurement: %s, %v", kind, msrID.String(), err)
+	}
+}
+
+func GetMeasurementEvents(msrID uuid.UUID) ([]models.MeasurementEvent, error) {
+	var events []models.MeasurementEvent
+	if err := database.DB.Where("msr_id = ?", msrID).Order("id desc").Find(&events).Error; err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+func msrSettings(msr models.PingMeasurement) map[string]any {
+	// JSON names of the settings match their column names
+	var settings map[string]any
+	encoded, _ := json.Marshal(msr)
+	json.Unmarshal(encoded, &settings)
+	return settings
+}
+
+func diffMsrSettings(before, after models.PingMeasurement) []SettingChange {
+	var changes []SettingChange
+	previous, current := msrSettings(before), msrSettings(after)
+	for _, column := range msrSettingColumns {
+		if !reflect.DeepEqual(previous[column], current[column]) {
+			changes = append(changes, SettingChange{Setting: column, From: previous[column], To: current[column]})
+		}
+	}
+	return changes
+}
+
+func DescribeSettingChanges(changes []SettingChange) string {
+	var parts []string
+	for _, change := range changes {
+		parts = append(parts, fmt.Sprintf("%s: %v -> %v", change.Setting, change.From, change.To))
+	}
+	return "Settings changed, " + strings.Join(parts, ", ")
+}
+
+func UpdateMsrSettings(before, msr models.PingMeasurement) (models.PingMeasurement, []SettingChange, error) {
+	ApplyPingDefaults(&msr)
+	if err := ValidatePingSettings(msr); err != nil {
+		return before, nil, err
+	}
+	if err := CheckMeasurementPolicy(msr, false); err != nil {
+		return before, nil, err
+	}
+	changes := diffMsrSettings(before, msr)
+	if len(changes) == 0 {
+		return before, nil, errors.New("No settings were changed.")
+	}
+	columns := make([]string, len(changes))
+	for i, change := range changes {
+		columns[i] = change.Setting
+	}
+	if err := database.DB.Model(&msr).Select(columns).Updates(&msr).Error; err != nil {
+		return before, nil, errors.Wrap(err, "Problem saving measurement settings to database.")
+	}
+	return msr, changes, nil
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Result and alert history limits
+const (
+	DefaultHistoryLimit = 100
+	MaxHistoryLimit     = 1000
+	DefaultHistorySort  = "-timestamp"
+)
+
+type HistoryOptions struct {
+	Limit  int
+	Cursor string
+	From   string
+	To     string
+	Fields string
+	Sort   string
+}
+
+// Result and alert rows have no primary key, sqlite's rowid breaks timestamp ties
+type historyRow[T any] struct {
+	RowID  int64 `gorm:"column:row_id"`
+	Record T     `gorm:"embedded"`
+}
+
+type historyCursor struct {
+	Timestamp string `json:"t"`
+	RowID     int64  `json:"r"`
+}
+
+func encodeHistoryCursor(timestamp string, rowID int64) string {
+	cursor, _ := json.Marshal(historyCursor{Timestamp: timestamp, RowID: rowID})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+func decodeHistoryCursor(encoded string) (historyCursor, error) {
+	var cursor historyCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(decoded, &cursor)
+	}
+	if err != nil || cursor.RowID == 0 {
+		return cursor, errors.New("Cursor is not valid, use the next_cursor value of a previous response.")
+	}
+	return cursor, nil
+}
+
+func recordFields(record any) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	encoded, _ := json.Marshal(record)
+	json.Unmarshal(encoded, &fields)
+	return fields
+}
+
+func parseHistoryFields[T any](fields string) ([]string, error) {
+	if fields == "" {
+		return nil, nil
+	}
+	available := recordFields(new(T))
+	var selected []string
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, ok := available[field]; !ok {
+			names := make([]string, 0, len(available))
+			for name := range available {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, errors.Errorf("Field: '%s' is not supported, use any of %s.", field, strings.Join(names, ", "))
+		}
+		selected = append(selected, field)
+	}
+	return selected, nil
+}
+
+func getHistory[T any](table, timeColumn string, msrID uuid.UUID, options HistoryOptions) ([]any, string, error) {
+	records := []any{}
+	if options.Limit == 0 {
+		options.Limit = DefaultHistoryLimit
+	}
+	if options.Limit < 0 || options.Limit > MaxHistoryLimit {
+		return records, "", errors.Errorf("Limit: %d is not supported, value should be between 1 and %d.", options.Limit, MaxHistoryLimit)
+	}
+	if options.Sort == "" {
+		options.Sort = DefaultHistorySort
+	}
+	direction, comparison := "asc", ">"
+	switch options.Sort {
+	case "timestamp":
+	case "-timestamp":
+		direction, comparison = "desc", "<"
+	default:
+		return records, "", errors.Errorf("Sort: '%s' is not supported, use timestamp or -timestamp.", options.Sort)
+	}
+	// Timestamps are stored as local time strings, bounds in another offset would not compare
+	for _, bound := range []*string{&options.From, &options.To} {
+		if *bound == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, *bound)
+		if err != nil {
+			return records, "", errors.Errorf("Time: '%s' should be in RFC3339 format.", *bound)
+		}
+		*bound = parsed.Local().Format(time.RFC3339)
+	}
+	fields, err := parseHistoryFields[T](options.Fields)
+	if err != nil {
+		return records, "", err
+	}
+	query := database.DB.Table(table).Select("rowid AS row_id, *").Where("msr_id = ?", msrID)
+	if options.From != "" {
+		query = query.Where(timeColumn+" >= ?", options.From)
+	}
+	if options.To != "" {
+		query = query.Where(timeColumn+" <= ?", options.To)
+	}
+	if options.Cursor != "" {
+		cursor, err := decodeHistoryCursor(options.Cursor)
+		if err != nil {
+			return records, "", err
+		}
+		query = query.Where("("+timeColumn+" "+comparison+" ? OR ("+timeColumn+" = ? AND rowid "+comparison+" ?))", cursor.Timestamp, cursor.Timestamp, cursor.RowID)
+	}
+	// One extra row tells whether there is a next page
+	var rows []historyRow[T]
+	if err := query.Order(timeColumn + " " + direction).Order("rowid " + direction).Limit(options.Limit + 1).Find(&rows).Error; err != nil {
+		return records, "", err
+	}
+	nextCursor := ""
+	if len(rows) > options.Limit {
+		rows = rows[:options.Limit]
+		last := rows[len(rows)-1]
+		var timestamp string
+		json.Unmarshal(recordFields(last.Record)[timeColumn], &timestamp)
+		nextCursor = encodeHistoryCursor(timestamp, last.RowID)
+	}
+	for _, row := range rows {
+		if fields == nil {
+			records = append(records, row.Record)
+			continue
+		}
+		all := recordFields(row.Record)
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			selected[field] = all[field]
+		}
+		records = append(records, selected)
+	}
+	return records, nextCursor, nil
+}
+
+func GetMeasurementResults(msrID uuid.UUID, options HistoryOptions) ([]any, string, error) {
+	return getHistory[models.MeasurementResults]("measurement_results", "timestamp", msrID, options)
+}
+
+func GetMeasurementAlerts(msrID uuid.UUID, options HistoryOptions) ([]any, string, error) {
+	return getHistory[models.MeasurementResultAlerts]("measurement_result_alerts", "alert_timestamp", msrID, options)
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/gorm/clause"
+)
+
+// IP metadata cache
+const (
+	ipCacheTTL         = 24 * time.Hour
+	ipCacheNegativeTTL = 15 * time.Minute
+	ptrCacheTTL        = 6 * time.Hour
+	ptrLookupTimeout   = 2 * time.Second
+)
+
+type IPCacheStats struct {
+	Lookups       int64   `json:"lookups"`
+	Hits          int64   `json:"hits"`
+	NegativeHits  int64   `json:"negative_hits"`
+	Deduplicated  int64   `json:"deduplicated"`
+	Misses        int64   `json:"misses"`
+	Failures      int64   `json:"failures"`
+	HitRatio      float64 `json:"hit_ratio"`
+	CachedEntries int64   `json:"cached_entries"`
+}
+
+type ipLookupCall struct {
+	wg   sync.WaitGroup
+	info models.IPMetadataCache
+}
+
+var (
+	ipCacheMu       sync.Mutex
+	ipCacheInFlight = make(map[string]*ipLookupCall)
+	ipCacheCounters struct {
+		lookups, hits, negativeHits, deduplicated, misses, failures int64
+	}
+)
+
+func getCachedIPInfo(ipAddr string) (models.IPMetadataCache, bool) {
+	var cached models.IPMetadataCache
+	if err := database.DB.First(&cached, "ip_address = ?", ipAddr).Error; err != nil {
+		return cached, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, cached.ExpiresAt)
+	if err != nil || time.Now().After(expiresAt) {
+		return cached, false
+	}
+	return cached, true
+}
+
+func refreshCachedIPInfo(ipAddr string) models.IPMetadataCache {
+	now := time.Now()
+	cached := models.IPMetadataCache{
+		IPAddress: ipAddr,
+		FetchedAt: now.Format(time.RFC3339),
+	}
+	ipInfo, err := ipAddrLookupRemote(ipAddr)
+	if err != nil {
+		log.Println("[!] 'refreshCachedIPInfo' - Lookup failed, caching negative result:", err)
+		atomic.AddInt64(&ipCacheCounters.failures, 1)
+		cached.ISP, cached.ASN, cached.Country, cached.CountryCode = "N/A", "N/A", "N/A", "N/A"
+		cached.Failed = true
+		cached.ExpiresAt = now.Add(ipCacheNegativeTTL).Format(time.RFC3339)
+	} else {
+		cached.ISP, cached.ASN, cached.Country, cached.CountryCode = ipInfo.Isp, ipInfo.As, ipInfo.Country, ipInfo.CountryCode
+		cached.ExpiresAt = now.Add(ipCacheTTL).Format(time.RFC3339)
+	}
+	// Reverse DNS is cached on the same row and refreshed separately
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ip_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"isp", "asn", "country", "country_code", "failed", "fetched_at", "expires_at"}),
+	}).Create(&cached).Error; err != nil {
+		log.Println("[!] 'refreshCachedIPInfo' - Could not store lookup result in cache:", err)
+	}
+	return cached
+}
+
+func cachedIPAddrLookup(ipAddr string) models.IPMetadataCache {
+	atomic.AddInt64(&ipCacheCounters.lookups, 1)
+	if cached, ok := getCachedIPInfo(ipAddr); ok {
+		if cached.Failed {
+			atomic.AddInt64(&ipCacheCounters.negativeHits, 1)
+		} else {
+			atomic.AddInt64(&ipCacheCounters.hits, 1)
+		}
+		return cached
+	}
+	// De-duplicate concurrent lookups of the same IP
+	ipCacheMu.Lock()
+	if call, ok := ipCacheInFlight[ipAddr]; ok {
+		ipCacheMu.Unlock()
+		atomic.AddInt64(&ipCacheCounters.deduplicated, 1)
+		call.wg.Wait()
+		return call.info
+	}
+	call := &ipLookupCall{}
+	call.wg.Add(1)
+	ipCacheInFlight[ipAddr] = call
+	ipCacheMu.Unlock()
+	atomic.AddInt64(&ipCacheCounters.misses, 1)
+	call.info = refreshCachedIPInfo(ipAddr)
+	ipCacheMu.Lock()
+	delete(ipCacheInFlight, ipAddr)
+	ipCacheMu.Unlock()
+	call.wg.Done()
+	return call.info
+}
+
+func GetIPCacheStats() IPCacheStats {
+	stats := IPCacheStats{
+		Lookups:      atomic.LoadInt64(&ipCacheCounters.lookups),
+		Hits:         atomic.LoadInt64(&ipCacheCounters.hits),
+		NegativeHits: atomic.LoadInt64(&ipCacheCounters.negativeHits),
+		Deduplicated: atomic.LoadInt64(&ipCacheCounters.deduplicated),
+		Misses:       atomic.LoadInt64(&ipCacheCounters.misses),
+		Failures:     atomic.LoadInt64(&ipCacheCounters.failures),
+	}
+	if stats.Lookups > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.NegativeHits+stats.Deduplicated) / float64(stats.Lookups)
+	}
+	database.DB.Model(&models.IPMetadataCache{}).Count(&stats.CachedEntries)
+	return stats
+}
+
+func reverseLookup(ctx context.Context, ipAddr string) string {
+	var cached models.IPMetadataCache
+	if err := database.DB.First(&cached, "ip_address = ?", ipAddr).Error; err == nil {
+		if expiresAt, err := time.Parse(time.RFC3339, cached.PTRExpiresAt); err == nil && time.Now().Before(expiresAt) {
+			return cached.PTR
+		}
+	}
+	var ptr string
+	names, err := net.DefaultResolver.LookupAddr(ctx, ipAddr)
+	if err == nil && len(names) > 0 {
+		ptr = strings.TrimSuffix(names[0], ".")
+	} else if ctx.Err() != nil {
+		// Timed out lookups are retried on the next poll rather than cached as missing
+		return ""
+	}
+	cached = models.IPMetadataCache{
+		IPAddress:    ipAddr,
+		PTR:          ptr,
+		PTRExpiresAt: time.Now().Add(ptrCacheTTL).Format(time.RFC3339),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ip_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ptr", "ptr_expires_at"}),
+	}).Create(&cached).Error; err != nil {
+		log.Println("[!] 'reverseLookup' - Could not store PTR record in cache:", err)
+	}
+	return ptr
+}
+
+func ReverseLookupIPs(ipAddrs []string) map[string]string {
+	ptrs := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithTimeout(context.Background(), ptrLookupTimeout)
+	defer cancel()
+	for _, ipAddr := range RemoveDuplicates(ipAddrs) {
+		wg.Add(1)
+		go func(ipAddr string) {
+			defer wg.Done()
+			ptr := reverseLookup(ctx, ipAddr)
+			mu.Lock()
+			ptrs[ipAddr] = ptr
+			mu.Unlock()
+		}(ipAddr)
+	}
+	wg.Wait()
+	return ptrs
+}
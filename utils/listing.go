@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/gorm"
+)
+
+// Measurement listing limits
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+	DefaultListSort  = "created_at"
+	MaxTags          = 10
+)
+
+var (
+	listSortColumns = map[string]bool{"created_at": true, "last_poll_at": true, "target": true, "frequency": true, "status_name": true}
+	tagPattern      = regexp.MustCompile(`^[a-z0-9_.:-]{1,32}$`)
+)
+
+type ListOptions struct {
+	Limit        int
+	Cursor       string
+	Status       string
+	TargetPrefix string
+	Tag          string
+	Alerting     string
+	Sort         string
+}
+
+type LastResultSummary struct {
+	Timestamp string  `json:"timestamp"`
+	AvgRtt    float64 `json:"avg_rtt"`
+	Loss      float64 `json:"loss"`
+	Jitter    float64 `json:"jitter"`
+	MOS       float64 `json:"mos"`
+}
+
+type MeasurementSummary struct {
+	ID             uuid.UUID          `json:"id"`
+	CreatedAt      string             `json:"created_at"`
+	LastPollAt     string             `json:"last_poll_at"`
+	StoppedAt      string             `json:"stopped_at"`
+	OwnerID        uint               `json:"owner_id"`
+	TeamID         uint               `json:"team_id"`
+	Target         string             `json:"target"`
+	Tags           []string           `json:"tags"`
+	PacketCount    int                `json:"packet_count"`
+	Frequency      int                `json:"frequency"`
+	MtrMode        bool               `json:"mtr_mode"`
+	Multipath      bool               `json:"multipath"`
+	PmtuMode       bool               `json:"pmtu_mode"`
+	Status         int                `json:"status"`
+	StatusName     string             `json:"status_name"`
+	ResultCount    int                `json:"result_count"`
+	Alerting       bool               `json:"alerting"`
+	OpenAlertCount int                `json:"open_alert_count"`
+	LastResult     *LastResultSummary `json:"last_result"`
+}
+
+type listCursor struct {
+	Value any    `json:"v"`
+	ID    string `json:"id"`
+}
+
+func NormalizeTags(tags string) (string, error) {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !tagPattern.MatchString(tag) {
+			return "", errors.Errorf("Tag: '%s' is not supported, use up to 32 lowercase letters, digits or _.:- characters.", tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return "", errors.Errorf("A measurement can have at most %d tags.", MaxTags)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+func encodeListCursor(msr models.PingMeasurement, sortColumn string) string {
+	cursor, _ := json.Marshal(listCursor{Value: msrSettings(msr)[sortColumn], ID: msr.ID.String()})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+func decodeListCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(decoded, &cursor)
+	}
+	if err != nil || cursor.ID == "" {
+		return cursor, errors.New("Cursor is not valid, use the next_cursor value of a previous response.")
+	}
+	return cursor, nil
+}
+
+// Latest result of a measurement, used to tell whether it is alerting right now
+const latestResultCondition = "timestamp = (SELECT MAX(latest.timestamp) FROM measurement_results latest WHERE latest.msr_id = measurement_results.msr_id)"
+
+func ListMeasurements(query *gorm.DB, options ListOptions) ([]MeasurementSummary, string, error) {
+	summaries := []MeasurementSummary{}
+	if options.Limit == 0 {
+		options.Limit = DefaultListLimit
+	}
+	if options.Limit < 0 || options.Limit > MaxListLimit {
+		return summaries, "", errors.Errorf("Limit: %d is not supported, value should be between 1 and %d.", options.Limit, MaxListLimit)
+	}
+	if options.Sort == "" {
+		options.Sort = DefaultListSort
+	}
+	sortColumn, direction, comparison := strings.TrimPrefix(options.Sort, "-"), "asc", ">"
+	if strings.HasPrefix(options.Sort, "-") {
+		direction, comparison = "desc", "<"
+	}
+	if !listSortColumns[sortColumn] {
+		return summaries, "", errors.Errorf("Sort: '%s' is not supported, use one of created_at, last_poll_at, target, frequency, status_name with an optional - prefix.", options.Sort)
+	}
+	if options.Status != "" {
+		query = query.Where("status_name = ?", strings.ToUpper(options.Status))
+	}
+	if options.TargetPrefix != "" {
+		query = query.Where("target LIKE ? ESCAPE '\\'", strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(options.TargetPrefix)+"%")
+	}
+	if options.Tag != "" {
+		query = query.Where("(',' || tags || ',') LIKE ?", "%,"+strings.ToLower(options.Tag)+",%")
+	}
+	alertingNow := database.DB.Table("measurement_results").Select("msr_id").Where("alerting = ?", true).Where(latestResultCondition)
+	switch options.Alerting {
+	case "":
+	case "true":
+		query = query.Where("id IN (?)", alertingNow)
+	case "false":
+		query = query.Where("id NOT IN (?)", alertingNow)
+	default:
+		return summaries, "", errors.Errorf("Alerting: '%s' is not supported, use true or false.", options.Alerting)
+	}
+	if options.Cursor != "" {
+		cursor, err := decodeListCursor(options.Cursor)
+		if err != nil {
+			return summaries, "", err
+		}
+		query = query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortColumn, comparison), cursor.Value, cursor.Value, cursor.ID)
+	}
+	// One extra row tells whether there is a next page
+	var msrs []models.PingMeasurement
+	if err := query.Order(sortColumn + " " + direction).Order("id " + direction).Limit(options.Limit + 1).Find(&msrs).Error; err != nil {
+		return summaries, "", err
+	}
+	nextCursor := ""
+	if len(msrs) > options.Limit {
+		msrs = msrs[:options.Limit]
+		nextCursor = encodeListCursor(msrs[len(msrs)-1], sortColumn)
+	}
+	summaries, err := summariseMeasurements(msrs)
+	return summaries, nextCursor, err
+}
+
+func summariseMeasurements(msrs []models.PingMeasurement) ([]MeasurementSummary, error) {
+	summaries := []MeasurementSummary{}
+	if len(msrs) == 0 {
+		return summaries, nil
+	}
+	msrIDs := make([]uuid.UUID, len(msrs))
+	for i, msr := range msrs {
+		msrIDs[i] = msr.ID
+	}
+	var counts []struct {
+		MsrID uuid.UUID
+		Count int
+	}
+	if err := database.DB.Model(&models.MeasurementResults{}).Select("msr_id, COUNT(*) AS count").
+		Where("msr_id IN ?", msrIDs).Group("msr_id").Scan(&counts).Error; err != nil {
+		return summaries, err
+	}
+	resultCounts := map[uuid.UUID]int{}
+	for _, count := range counts {
+		resultCounts[count.MsrID] = count.Count
+	}
+	var latest []models.MeasurementResults
+	if err := database.DB.Where("msr_id IN ?", msrIDs).Where(latestResultCondition).Find(&latest).Error; err != nil {
+		return summaries, err
+	}
+	latestResults := map[uuid.UUID]models.MeasurementResults{}
+	for _, result := range latest {
+		latestResults[result.MsrID] = result
+	}
+	for _, msr := range msrs {
+		summary := MeasurementSummary{
+			ID:          msr.ID,
+			CreatedAt:   msr.CreatedAt,
+			LastPollAt:  msr.LastPollAt,
+			StoppedAt:   msr.StoppedAt,
+			OwnerID:     msr.OwnerID,
+			TeamID:      msr.TeamID,
+			Target:      msr.Target,
+			Tags:        splitTags(msr.Tags),
+			PacketCount: msr.PacketCount,
+			Frequency:   msr.Frequency,
+			MtrMode:     msr.MtrMode,
+			Multipath:   msr.Multipath,
+			PmtuMode:    msr.PmtuMode,
+			Status:      msr.Status,
+			StatusName:  msr.StatusName,
+			ResultCount: resultCounts[msr.ID],
+		}
+		if result, ok := latestResults[msr.ID]; ok {
+			summary.Alerting = result.Alerting
+			summary.LastResult = &LastResultSummary{
+				Timestamp: result.Timestamp,
+				AvgRtt:    result.AvgRtt,
+				Loss:      result.Loss,
+				Jitter:    result.Jitter,
+				MOS:       result.MOS,
+			}
+		}
+		if summary.Alerting {
+			// Alerts raised since the last healthy poll are still open
+			var lastHealthy models.MeasurementResults
+			database.DB.Where("msr_id = ? AND alerting = ?", msr.ID, false).Order("timestamp desc").Limit(1).Find(&lastHealthy)
+			var openAlerts int64
+			if err := database.DB.Model(&models.MeasurementResultAlerts{}).
+				Where("msr_id = ? AND alert_timestamp > ?", msr.ID, lastHealthy.Timestamp).Count(&openAlerts).Error; err != nil {
+				return summaries, err
+			}
+			summary.OpenAlertCount = int(openAlerts)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
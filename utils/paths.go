@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/gorm"
+)
+
+// Hop of a path whose probes all timed out
+const TimeoutHop = "*"
+
+var legacyHopLabel = regexp.MustCompile(`^(\S+)(?: \[(.*)\])? \((.*)\)$`)
+
+func pathHash(hops []models.MeasurementPathHops) string {
+	// Hops are keyed by TTL, so ECMP responders and silent hops are part of the path
+	var keys []string
+	for _, hop := range hops {
+		keys = append(keys, fmt.Sprintf("%d:%s", hop.TTL, hop.IPAddress))
+	}
+	sum := sha1.Sum([]byte(strings.Join(keys, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Hops grouped by TTL in TTL order, responders of a TTL are the ECMP alternatives
+func HopsByTTL(hops []models.MeasurementPathHops) [][]models.MeasurementPathHops {
+	var groups [][]models.MeasurementPathHops
+	for _, hop := range hops {
+		if len(groups) == 0 || groups[len(groups)-1][0].TTL != hop.TTL {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], hop)
+	}
+	return groups
+}
+
+// AS and IP path of a stored path for display, ECMP responders are separated by "|"
+func PathSummary(hops []models.MeasurementPathHops) (string, string) {
+	var asPath, ipPath []string
+	for _, group := range HopsByTTL(hops) {
+		var ips []string
+		for _, hop := range group {
+			ips = append(ips, hop.IPAddress)
+			if _, ok := SpecialPurposeLabel(hop.IPAddress); ok || hop.IPAddress == TimeoutHop {
+				continue
+			}
+			asPath = append(asPath, hop.ASN)
+		}
+		ipPath = append(ipPath, strings.Join(ips, "|"))
+	}
+	return strings.Join(RemoveDuplicates(asPath), " > "), strings.Join(ipPath, " > ")
+}
+
+func SaveMeasurementPath(msrID uuid.UUID, hops []models.MeasurementPathHops, timestamp string) (models.MeasurementPaths, error) {
+	return saveMeasurementPath(database.DB, msrID, hops, timestamp)
+}
+
+func saveMeasurementPath(db *gorm.DB, msrID uuid.UUID, hops []models.MeasurementPathHops, timestamp string) (models.MeasurementPaths, error) {
+	var path models.MeasurementPaths
+	if len(hops) == 0 {
+		return path, errors.New("Path has no hops, nothing to store.")
+	}
+	hash := pathHash(hops)
+	if err := db.First(&path, "msr_id = ? AND path_hash = ?", msrID, hash).Error; err == nil {
+		path.LastSeen = timestamp
+		path.TimesSeen++
+		if err := db.Save(&path).Error; err != nil {
+			return path, err
+		}
+		return path, nil
+	}
+	for index := range hops {
+		hops[index].HopIndex = index
+	}
+	path = models.MeasurementPaths{
+		MsrID:     msrID,
+		PathHash:  hash,
+		FirstSeen: timestamp,
+		LastSeen:  timestamp,
+		TimesSeen: 1,
+		Hops:      hops,
+	}
+	if err := db.Create(&path).Error; err != nil {
+		return path, errors.Wrap(err, "Problem saving path to database.")
+	}
+	return path, nil
+}
+
+func GetMeasurementPath(pathID uint) (models.MeasurementPaths, error) {
+	var path models.MeasurementPaths
+	if err := database.DB.Preload("Hops", func(db *gorm.DB) *gorm.DB {
+		return db.Order("hop_index")
+	}).First(&path, "id = ?", pathID).Error; err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+func GetMeasurementPaths(msrID uuid.UUID, since time.Time) ([]models.MeasurementPaths, error) {
+	var paths []models.MeasurementPaths
+	if err := database.DB.Preload("Hops", func(db *gorm.DB) *gorm.DB {
+		return db.Order("hop_index")
+	}).Where("msr_id = ? AND last_seen >= ?", msrID, since.Format(time.RFC3339)).Order("last_seen desc").Find(&paths).Error; err != nil {
+		return paths, err
+	}
+	return paths, nil
+}
+
+func deleteMeasurementPaths(msrID string) error {
+	pathIDs := database.DB.Model(&models.MeasurementPaths{}).Select("id").Where("msr_id = ?", msrID)
+	if err := database.DB.Where("path_id IN (?)", pathIDs).Delete(&models.MeasurementPathHops{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("msr_id = ?", msrID).Delete(&models.MeasurementPaths{}).Error
+}
+
+func GetLatestMultipathSet(msrID uuid.UUID) ([]models.MeasurementPaths, error) {
+	var latest models.MeasurementResultPaths
+	var resultPaths []models.MeasurementResultPaths
+	var paths []models.MeasurementPaths
+	// Result paths have no primary key, Last would order by msr_id
+	if err := database.DB.Where("msr_id = ?", msrID).Order("timestamp desc").First(&latest).Error; err != nil {
+		return paths, err
+	}
+	if err := database.DB.Where("msr_id = ? AND timestamp = ?", msrID, latest.Timestamp).Find(&resultPaths).Error; err != nil {
+		return paths, err
+	}
+	for _, resultPath := range resultPaths {
+		path, err := GetMeasurementPath(resultPath.PathID)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Latest result with a catalogued path, results whose trace failed have none
+func GetLatestPathResult(msrID uuid.UUID) (models.MeasurementResults, error) {
+	var result models.MeasurementResults
+	if err := database.DB.Where("msr_id = ? AND path_id <> 0", msrID).Order("timestamp desc").First(&result).Error; err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+type legacyResultPath struct {
+	RowID        int64     `gorm:"column:row_id"`
+	MsrID        uuid.UUID `gorm:"column:msr_id"`
+	Timestamp    string    `gorm:"column:timestamp"`
+	CombinedPath string    `gorm:"column:combined_path"`
+}
+
+func MigrateResultPaths() {
+	// Hops stored before TTLs were recorded had one responder per TTL
+	if err := database.DB.Model(&models.MeasurementPathHops{}).Where("ttl = 0").Update("ttl", gorm.Expr("hop_index + 1")).Error; err != nil {
+		log.Println("[!] 'MigrateResultPaths' - Could not set TTL of stored hops:", err)
+	}
+	migrator := database.DB.Migrator()
+	if !migrator.HasColumn(&models.MeasurementResults{}, "combined_path") {
+		return
+	}
+	// Results stored before paths were catalogued only have the path as text
+	var legacy []legacyResultPath
+	if err := database.DB.Table("measurement_results").Select("rowid AS row_id, msr_id, timestamp, combined_path").
+		Where("path_id = 0 AND combined_path <> ''").Order("rowid").Find(&legacy).Error; err != nil {
+		log.Println("[!] 'MigrateResultPaths' - Could not load results without a path:", err)
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		failed := 0
+		for _, result := range legacy {
+			if err := migrateResultPath(tx, result); err != nil {
+				log.Printf("[!] 'MigrateResultPaths' - Could not catalogue path of result: %s %s, %v", result.MsrID, result.Timestamp, err)
+				failed++
+			}
+		}
+		// The text columns are the only copy of paths which could not be catalogued
+		if failed > 0 {
+			return errors.Errorf("%d results could not be migrated, keeping the legacy path columns.", failed)
+		}
+		for _, column := range []string{"ip_path", "as_path", "combined_path"} {
+			if err := tx.Migrator().DropColumn(&models.MeasurementResults{}, column); err != nil {
+				return errors.Wrapf(err, "Could not drop column: %s.", column)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("[!] 'MigrateResultPaths' -", err)
+	}
+}
+
+func migrateResultPath(tx *gorm.DB, result legacyResultPath) error {
+	var hops []models.MeasurementPathHops
+	for index, label := range strings.Split(result.CombinedPath, " > ") {
+		match := legacyHopLabel.FindStringSubmatch(label)
+		if match == nil {
+			return errors.Errorf("Hop: '%s' is not a path label.", label)
+		}
+		hops = append(hops, models.MeasurementPathHops{TTL: index + 1, IPAddress: match[1], PTR: match[2], ASN: match[3]})
+	}
+	path, err := saveMeasurementPath(tx, result.MsrID, hops, result.Timestamp)
+	if err != nil {
+		return err
+	}
+	return tx.Table("measurement_results").Where("rowid = ?", result.RowID).Update("path_id", path.ID).Error
+}
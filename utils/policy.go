@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Measurement policy defaults, admins can change them at runtime
+const (
+	DefaultMinFrequency           = 3
+	DefaultMaxPacketCount         = MaxPacketCount
+	DefaultMaxMeasurementsPerUser = 20
+)
+
+func GetMeasurementPolicy() models.MeasurementPolicy {
+	policy := models.MeasurementPolicy{
+		MinFrequency:           DefaultMinFrequency,
+		MaxPacketCount:         DefaultMaxPacketCount,
+		MaxMeasurementsPerUser: DefaultMaxMeasurementsPerUser,
+	}
+	database.DB.Limit(1).Find(&policy)
+	return policy
+}
+
+func UpdateMeasurementPolicy(policy models.MeasurementPolicy) (models.MeasurementPolicy, error) {
+	if policy.MinFrequency < 1 {
+		return policy, errors.Errorf("Minimum frequency: %d is not supported, value should be at least 1 minute.", policy.MinFrequency)
+	}
+	if policy.MaxPacketCount < 1 || policy.MaxPacketCount > MaxPacketCount {
+		return policy, errors.Errorf("Maximum packet count: %d is not supported, value should be between 1 and %d.", policy.MaxPacketCount, MaxPacketCount)
+	}
+	if policy.MaxMeasurementsPerUser < 0 {
+		return policy, errors.Errorf("Maximum measurements per user: %d is not supported, use 0 for no limit.", policy.MaxMeasurementsPerUser)
+	}
+	// There is only ever one policy row
+	policy.ID = 1
+	policy.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := database.DB.Save(&policy).Error; err != nil {
+		return policy, errors.Wrap(err, "Problem saving measurement policy to database.")
+	}
+	return policy, nil
+}
+
+func parsePrefix(prefix string) (*net.IPNet, error) {
+	// A bare address denies just that host
+	if ip := net.ParseIP(prefix); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, errors.Errorf("Prefix: '%s' is not a valid IP address or CIDR prefix.", prefix)
+	}
+	return ipNet, nil
+}
+
+func AddDeniedPrefix(prefix, reason string) (models.DeniedPrefix, error) {
+	denied := models.DeniedPrefix{Reason: reason, CreatedAt: time.Now().Format(time.RFC3339)}
+	ipNet, err := parsePrefix(prefix)
+	if err != nil {
+		return denied, err
+	}
+	denied.Prefix = ipNet.String()
+	if err := database.DB.Create(&denied).Error; err != nil {
+		return denied, errors.Wrap(err, "Problem saving denied prefix to database.")
+	}
+	return denied, nil
+}
+
+func GetDeniedPrefixes() ([]models.DeniedPrefix, error) {
+	var prefixes []models.DeniedPrefix
+	if err := database.DB.Order("prefix").Find(&prefixes).Error; err != nil {
+		return prefixes, err
+	}
+	return prefixes, nil
+}
+
+func DeleteDeniedPrefix(prefixID int) (models.DeniedPrefix, error) {
+	var denied models.DeniedPrefix
+	if err := database.DB.First(&denied, "id = ?", prefixID).Error; err != nil {
+		return denied, errors.Errorf("Denied prefix: %d not found.", prefixID)
+	}
+	if err := database.DB.Delete(&denied).Error; err != nil {
+		return denied, err
+	}
+	return denied, nil
+}
+
+func DeniedPrefixFor(target string) (models.DeniedPrefix, bool) {
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return models.DeniedPrefix{}, false
+	}
+	prefixes, err := GetDeniedPrefixes()
+	if err != nil {
+		return models.DeniedPrefix{}, false
+	}
+	for _, denied := range prefixes {
+		if _, ipNet, err := net.ParseCIDR(denied.Prefix); err == nil && ipNet.Contains(ip) {
+			return denied, true
+		}
+	}
+	return models.DeniedPrefix{}, false
+}
+
+func storedMeasurement(msrID uuid.UUID) (models.PingMeasurement, bool) {
+	var stored models.PingMeasurement
+	if msrID == uuid.Nil {
+		return stored, false
+	}
+	err := database.DB.First(&stored, "id = ?", msrID).Error
+	return stored, err == nil
+}
+
+// Private ranges are deliberately allowed, probing the local network is a common use,
+// admins who do not want that add them to the denied prefixes
+func checkTargetAddress(target string) error {
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return errors.Errorf("Target: %s is not allowed, loopback, link-local, multicast and unspecified addresses can not be measured.", target)
+	}
+	return nil
+}
+
+func CheckMeasurementPolicy(msr models.PingMeasurement, enforceCap bool) error {
+	policy := GetMeasurementPolicy()
+	stored, exists := storedMeasurement(msr.ID)
+	// Measurements keep polling at the frequency they already have when the minimum is raised
+	if msr.Frequency < policy.MinFrequency && !(exists && stored.Frequency == msr.Frequency) {
+		return errors.Errorf("Frequency: %d is below the minimum of %d minutes allowed by policy.", msr.Frequency, policy.MinFrequency)
+	}
+	if msr.PacketCount > policy.MaxPacketCount {
+		return errors.Errorf("Packet count: %d is above the maximum of %d allowed by policy.", msr.PacketCount, policy.MaxPacketCount)
+	}
+	if !exists {
+		if err := checkTargetAddress(msr.Target); err != nil {
+			return err
+		}
+	}
+	if denied, ok := DeniedPrefixFor(msr.Target); ok {
+		return errors.Errorf("Target: %s is not allowed, it falls within denied prefix %s.", msr.Target, denied.Prefix)
+	}
+	if enforceCap && policy.MaxMeasurementsPerUser > 0 {
+		var count int64
+		if err := database.DB.Model(&models.PingMeasurement{}).Where("owner_id = ?", msr.OwnerID).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) >= policy.MaxMeasurementsPerUser {
+			return errors.Errorf("You already have %d measurements, the maximum allowed by policy is %d.", count, policy.MaxMeasurementsPerUser)
+		}
+	}
+	return nil
+}
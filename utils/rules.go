@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Alert rules
+const (
+	DefaultRuleWindow = 60
+	MaxRuleWindow     = 7 * 24 * 60
+)
+
+var alertRuleMetrics = map[string]func(MeasurementStats) float64{
+	"avg_rtt":        func(s MeasurementStats) float64 { return s.AvgRtt },
+	"max_rtt":        func(s MeasurementStats) float64 { return s.MaxRtt },
+	"p50_rtt":        func(s MeasurementStats) float64 { return s.P50Rtt },
+	"p90_rtt":        func(s MeasurementStats) float64 { return s.P90Rtt },
+	"p95_rtt":        func(s MeasurementStats) float64 { return s.P95Rtt },
+	"p99_rtt":        func(s MeasurementStats) float64 { return s.P99Rtt },
+	"jitter":         func(s MeasurementStats) float64 { return s.Jitter },
+	"mos":            func(s MeasurementStats) float64 { return s.MOS },
+	"r_factor":       func(s MeasurementStats) float64 { return s.RFactor },
+	"loss":           func(s MeasurementStats) float64 { return s.Loss },
+	"loss_run":       func(s MeasurementStats) float64 { return float64(s.LossRuns.Longest) },
+	"loss_run_count": func(s MeasurementStats) float64 { return float64(s.LossRuns.Count) },
+}
+
+var alertRuleOperators = map[string]func(value, threshold float64) bool{
+	"gt":  func(value, threshold float64) bool { return value > threshold },
+	"gte": func(value, threshold float64) bool { return value >= threshold },
+	"lt":  func(value, threshold float64) bool { return value < threshold },
+	"lte": func(value, threshold float64) bool { return value <= threshold },
+}
+
+func ValidateAlertRule(rule models.AlertRule) error {
+	if _, ok := alertRuleMetrics[rule.Metric]; !ok {
+		return errors.Errorf("Metric: '%s' is not supported.", rule.Metric)
+	}
+	if _, ok := alertRuleOperators[rule.Operator]; !ok {
+		return errors.Errorf("Operator: '%s' is not supported, use one of gt, gte, lt, lte.", rule.Operator)
+	}
+	if rule.Window <= 0 || rule.Window > MaxRuleWindow {
+		return errors.Errorf("Window: %d is not supported, value should be between 1 and %d minutes.", rule.Window, MaxRuleWindow)
+	}
+	return nil
+}
+
+func AddAlertRule(rule models.AlertRule) (models.AlertRule, error) {
+	if rule.Window == 0 {
+		rule.Window = DefaultRuleWindow
+	}
+	if err := ValidateAlertRule(rule); err != nil {
+		return rule, err
+	}
+	if err := database.DB.First(&models.PingMeasurement{}, "id = ?", rule.MsrID).Error; err != nil {
+		return rule, errors.Wrap(err, "Measurement not found.")
+	}
+	rule.CreatedAt = time.Now().Format(time.RFC3339)
+	if err := database.DB.Create(&rule).Error; err != nil {
+		return rule, errors.Wrap(err, "Problem saving alert rule to database.")
+	}
+	return rule, nil
+}
+
+func GetAlertRules(msrID uuid.UUID) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	if err := database.DB.Where("msr_id = ?", msrID).Order("id").Find(&rules).Error; err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+func DeleteAlertRule(msrID uuid.UUID, ruleID int) error {
+	result := database.DB.Where("msr_id = ? AND id = ?", msrID, ruleID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.Errorf("Alert rule: %d not found.", ruleID)
+	}
+	return nil
+}
+
+func EvaluateAlertRule(rule models.AlertRule) (float64, bool, error) {
+	metric, ok := alertRuleMetrics[rule.Metric]
+	if !ok {
+		return 0, false, errors.Errorf("Metric: '%s' is not supported.", rule.Metric)
+	}
+	compare, ok := alertRuleOperators[rule.Operator]
+	if !ok {
+		return 0, false, errors.Errorf("Operator: '%s' is not supported.", rule.Operator)
+	}
+	now := time.Now()
+	stats, err := GetMeasurementStats(rule.MsrID, now.Add(-time.Duration(rule.Window)*time.Minute), now, DefaultHistogramBuckets)
+	if err != nil {
+		return 0, false, err
+	}
+	// RTT metrics have no value when nothing was received in the window
+	if stats.Sent == 0 || (stats.Rcvd == 0 && (strings.HasSuffix(rule.Metric, "_rtt") || rule.Metric == "jitter")) {
+		return 0, false, nil
+	}
+	value := metric(stats)
+	return value, compare(value, rule.Threshold), nil
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// ICMP defaults and limits
+const (
+	DefaultPacketSize = 24
+	MinPacketSize     = 24
+	MaxPacketSize     = 1472
+	DefaultInterval   = 1000
+	MinInterval       = 100
+	MaxInterval       = 10000
+	DefaultTTL        = 64
+	MaxTTL            = 255
+	MaxTimeout        = 600
+	MaxDSCP           = 63
+	MaxPacketCount    = 100
+)
+
+// Health check thresholds, a loss threshold of 0 alerts on any lost packet
+const (
+	DefaultThresholdMinRtt = 50
+	DefaultThresholdMaxRtt = 500
+	DefaultThresholdAvgRtt = 100
+	DefaultThresholdJitter = 25
+	MaxThresholdLoss       = 100
+)
+
+func ApplyPingDefaults(msr *models.PingMeasurement) {
+	if msr.PacketSize == 0 {
+		msr.PacketSize = DefaultPacketSize
+	}
+	if msr.Interval == 0 {
+		msr.Interval = DefaultInterval
+	}
+	if msr.TTL == 0 {
+		msr.TTL = DefaultTTL
+	}
+	if msr.ThresholdMinRtt == 0 {
+		msr.ThresholdMinRtt = DefaultThresholdMinRtt
+	}
+	if msr.ThresholdMaxRtt == 0 {
+		msr.ThresholdMaxRtt = DefaultThresholdMaxRtt
+	}
+	if msr.ThresholdAvgRtt == 0 {
+		msr.ThresholdAvgRtt = DefaultThresholdAvgRtt
+	}
+	if msr.ThresholdJitter == 0 {
+		msr.ThresholdJitter = DefaultThresholdJitter
+	}
+	if msr.Timeout == 0 {
+		// Long enough for every packet to be sent at the configured interval
+		msr.Timeout = msr.PacketCount * msr.Interval / 1000
+		if msr.Timeout < 1 {
+			msr.Timeout = 1
+		}
+	}
+}
+
+func ValidatePingSettings(msr models.PingMeasurement) error {
+	if msr.PacketCount <= 0 || msr.PacketCount > MaxPacketCount {
+		return errors.Errorf("Packet count: %d is not supported, value should be between 1 and %d.", msr.PacketCount, MaxPacketCount)
+	}
+	if msr.PacketSize < MinPacketSize || msr.PacketSize > MaxPacketSize {
+		return errors.Errorf("Packet size: %d is not supported, value should be between %d and %d bytes.", msr.PacketSize, MinPacketSize, MaxPacketSize)
+	}
+	if msr.Interval < MinInterval || msr.Interval > MaxInterval {
+		return errors.Errorf("Interval: %d is not supported, value should be between %d and %d milliseconds.", msr.Interval, MinInterval, MaxInterval)
+	}
+	if msr.TTL < 1 || msr.TTL > MaxTTL {
+		return errors.Errorf("TTL: %d is not supported, value should be between 1 and %d.", msr.TTL, MaxTTL)
+	}
+	if msr.Timeout < 1 || msr.Timeout > MaxTimeout {
+		return errors.Errorf("Timeout: %d is not supported, value should be between 1 and %d seconds.", msr.Timeout, MaxTimeout)
+	}
+	if msr.Timeout*1000 < (msr.PacketCount-1)*msr.Interval {
+		return errors.Errorf("Timeout: %ds is too short to send %d packets every %dms.", msr.Timeout, msr.PacketCount, msr.Interval)
+	}
+	if msr.DSCP < 0 || msr.DSCP > MaxDSCP {
+		return errors.Errorf("DSCP: %d is not supported, value should be between 0 and %d.", msr.DSCP, MaxDSCP)
+	}
+	if msr.Frequency <= 0 {
+		return errors.Errorf("Frequency: %d is not supported, value should be at least 1 minute.", msr.Frequency)
+	}
+	if msr.Timeout >= msr.Frequency*60 {
+		return errors.Errorf("Timeout: %ds must be shorter than the polling frequency of %d minutes.", msr.Timeout, msr.Frequency)
+	}
+	thresholds := []struct {
+		name  string
+		value float64
+	}{{"min RTT", msr.ThresholdMinRtt}, {"max RTT", msr.ThresholdMaxRtt}, {"avg RTT", msr.ThresholdAvgRtt}, {"jitter", msr.ThresholdJitter}}
+	for _, threshold := range thresholds {
+		if threshold.value <= 0 {
+			return errors.Errorf("Threshold: %s of %.3fms is not supported, value should be above 0.", threshold.name, threshold.value)
+		}
+	}
+	if msr.ThresholdLoss < 0 || msr.ThresholdLoss >= MaxThresholdLoss {
+		return errors.Errorf("Threshold: loss of %.3f%% is not supported, value should be at least 0 and below %d.", msr.ThresholdLoss, MaxThresholdLoss)
+	}
+	return nil
+}
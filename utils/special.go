@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"net"
+)
+
+// Special-purpose address labels
+const (
+	LabelPrivate       = "PRIVATE"
+	LabelCGNAT         = "CGNAT"
+	LabelLoopback      = "LOOPBACK"
+	LabelLinkLocal     = "LINK_LOCAL"
+	LabelIXP           = "IXP"
+	LabelDocumentation = "DOCUMENTATION"
+	LabelBenchmark     = "BENCHMARK"
+	LabelMulticast     = "MULTICAST"
+	LabelReserved      = "RESERVED"
+)
+
+type specialPrefix struct {
+	network *net.IPNet
+	label   string
+}
+
+var specialPrefixes = mustParseSpecialPrefixes(map[string][]string{
+	LabelPrivate:       {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+	LabelCGNAT:         {"100.64.0.0/10"},
+	LabelLoopback:      {"127.0.0.0/8", "::1/128"},
+	LabelLinkLocal:     {"169.254.0.0/16", "fe80::/10"},
+	LabelDocumentation: {"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "2001:db8::/32"},
+	LabelBenchmark:     {"198.18.0.0/15"},
+	LabelMulticast:     {"224.0.0.0/4", "ff00::/8"},
+	LabelReserved:      {"0.0.0.0/8", "192.0.0.0/24", "240.0.0.0/4"},
+	// Peering LANs of the larger internet exchanges
+	LabelIXP: {
+		"80.249.208.0/21",  // AMS-IX
+		"80.81.192.0/21",   // DE-CIX Frankfurt
+		"195.66.224.0/22",  // LINX LON1
+		"206.126.236.0/22", // Equinix Ashburn
+		"185.1.0.0/16",     // RIPE NCC IXP assignments
+		"196.60.0.0/16",    // AFRINIC IXP assignments
+		"2001:7f8::/32",    // RIPE NCC IXP assignments
+		"2001:504::/32",    // ARIN IXP assignments
+		"2001:de8::/32",    // APNIC IXP assignments
+	},
+})
+
+func mustParseSpecialPrefixes(prefixes map[string][]string) []specialPrefix {
+	var parsed []specialPrefix
+	for label, cidrs := range prefixes {
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				panic(err)
+			}
+			parsed = append(parsed, specialPrefix{network: network, label: label})
+		}
+	}
+	return parsed
+}
+
+func SpecialPurposeLabel(ipAddr string) (string, bool) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return "", false
+	}
+	for _, prefix := range specialPrefixes {
+		if prefix.network.Contains(ip) {
+			return prefix.label, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+)
+
+// Statistics
+const (
+	DefaultHistogramBuckets = 20
+	MaxHistogramBuckets     = 200
+	// RFC 3550 moves the jitter estimate by 1/16 of each new difference
+	jitterGain = 16
+)
+
+type HistogramBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+type LossRunStats struct {
+	Count   int         `json:"count"`
+	Longest int         `json:"longest"`
+	Average float64     `json:"average"`
+	Lengths map[int]int `json:"lengths"`
+}
+
+type MeasurementStats struct {
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Sent      int               `json:"sent"`
+	Rcvd      int               `json:"rcvd"`
+	Loss      float64           `json:"loss"`
+	MinRtt    float64           `json:"min_rtt"`
+	AvgRtt    float64           `json:"avg_rtt"`
+	MaxRtt    float64           `json:"max_rtt"`
+	Jitter    float64           `json:"jitter"`
+	RFactor   float64           `json:"r_factor"`
+	MOS       float64           `json:"mos"`
+	P50Rtt    float64           `json:"p50_rtt"`
+	P90Rtt    float64           `json:"p90_rtt"`
+	P95Rtt    float64           `json:"p95_rtt"`
+	P99Rtt    float64           `json:"p99_rtt"`
+	LossRuns  LossRunStats      `json:"loss_runs"`
+	Histogram []HistogramBucket `json:"histogram"`
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	// Linear interpolation between the closest ranks
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func lossRuns(samples []models.MeasurementPacketSamples) LossRunStats {
+	stats := LossRunStats{Lengths: make(map[int]int)}
+	run, total := 0, 0
+	// A received sentinel closes a run that lasts until the end of the window
+	for _, sample := range append(samples, models.MeasurementPacketSamples{Received: true}) {
+		if !sample.Received {
+			run++
+			continue
+		}
+		if run > 0 {
+			stats.Count++
+			stats.Lengths[run]++
+			total += run
+			if run > stats.Longest {
+				stats.Longest = run
+			}
+			run = 0
+		}
+	}
+	if stats.Count > 0 {
+		stats.Average = float64(total) / float64(stats.Count)
+	}
+	return stats
+}
+
+// Jitter of every result, rule and MOS score: RFC 3550's interarrival jitter J += (|D| - J) / 16,
+// D being the RTT difference between consecutive replies. The estimate carries on from poll to poll
+// but pairs never span two polls, the time between polls is not packet spacing
+func PollJitter(polls [][]float64) float64 {
+	var jitter float64
+	for _, rtts := range polls {
+		for i := 1; i < len(rtts); i++ {
+			jitter += (math.Abs(rtts[i]-rtts[i-1]) - jitter) / jitterGain
+		}
+	}
+	return jitter
+}
+
+func sampleJitter(samples []models.MeasurementPacketSamples) float64 {
+	// Samples are ordered by poll timestamp and sequence
+	var polls [][]float64
+	for i, sample := range samples {
+		if i == 0 || sample.Timestamp != samples[i-1].Timestamp {
+			polls = append(polls, nil)
+		}
+		if sample.Received {
+			polls[len(polls)-1] = append(polls[len(polls)-1], sample.Rtt)
+		}
+	}
+	return PollJitter(polls)
+}
+
+func rttHistogram(sorted []float64, buckets int) []HistogramBucket {
+	histogram := []HistogramBucket{}
+	if len(sorted) == 0 {
+		return histogram
+	}
+	min, max := sorted[0], sorted[len(sorted)-1]
+	width := (max - min) / float64(buckets)
+	if width == 0 {
+		return append(histogram, HistogramBucket{From: min, To: max, Count: len(sorted)})
+	}
+	for i := 0; i < buckets; i++ {
+		histogram = append(histogram, HistogramBucket{From: min + float64(i)*width, To: min + float64(i+1)*width})
+	}
+	for _, rtt := range sorted {
+		index := int((rtt - min) / width)
+		if index >= buckets {
+			index = buckets - 1
+		}
+		histogram[index].Count++
+	}
+	return histogram
+}
+
+func GetMeasurementStats(msrID uuid.UUID, from, to time.Time, buckets int) (MeasurementStats, error) {
+	// Samples carry local time strings, bounds in another offset would not compare
+	stats := MeasurementStats{From: from.Local().Format(time.RFC3339), To: to.Local().Format(time.RFC3339)}
+	if !from.Before(to) {
+		return stats, errors.New("Start of the window must be before its end.")
+	}
+	if buckets <= 0 || buckets > MaxHistogramBuckets {
+		return stats, errors.Errorf("Buckets: %d is not supported, value should be between 1 and %d.", buckets, MaxHistogramBuckets)
+	}
+	var samples []models.MeasurementPacketSamples
+	if err := database.DB.Where("msr_id = ? AND timestamp >= ? AND timestamp <= ?", msrID, stats.From, stats.To).
+		Order("timestamp, seq").Find(&samples).Error; err != nil {
+		return stats, err
+	}
+	var rtts []float64
+	for _, sample := range samples {
+		if sample.Received {
+			rtts = append(rtts, sample.Rtt)
+		}
+	}
+	sort.Float64s(rtts)
+	stats.Sent, stats.Rcvd = len(samples), len(rtts)
+	if stats.Sent > 0 {
+		stats.Loss = float64(stats.Sent-stats.Rcvd) / float64(stats.Sent) * 100
+	}
+	if stats.Rcvd > 0 {
+		var total float64
+		for _, rtt := range rtts {
+			total += rtt
+		}
+		stats.MinRtt, stats.MaxRtt = rtts[0], rtts[len(rtts)-1]
+		stats.AvgRtt = total / float64(stats.Rcvd)
+		stats.Jitter = sampleJitter(samples)
+	}
+	if stats.Sent > 0 {
+		stats.RFactor, stats.MOS = VoiceQuality(stats.AvgRtt/2, stats.Jitter, stats.Loss)
+	}
+	stats.P50Rtt = percentile(rtts, 50)
+	stats.P90Rtt = percentile(rtts, 90)
+	stats.P95Rtt = percentile(rtts, 95)
+	stats.P99Rtt = percentile(rtts, 99)
+	stats.LossRuns = lossRuns(samples)
+	stats.Histogram = rttHistogram(rtts, buckets)
+	return stats, nil
+}
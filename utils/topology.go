@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/gorm"
+)
+
+type pathOccurrence struct {
+	PathID    uint
+	Timestamp string
+}
+
+type topologyEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    int    `json:"value"`
+	Label    string `json:"label"`
+	Title    string `json:"title"`
+	LastSeen string `json:"last_seen"`
+}
+
+type topologyNode struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Title    string `json:"title"`
+	ASN      string `json:"asn"`
+	PTR      string `json:"ptr"`
+	Group    string `json:"group"`
+	Seen     int    `json:"seen"`
+	LastSeen string `json:"last_seen"`
+}
+
+func getPathOccurrences(msrID uuid.UUID, since string) ([]pathOccurrence, error) {
+	var occurrences, multipathOccurrences []pathOccurrence
+	if err := database.DB.Model(&models.MeasurementResults{}).
+		Select("path_id, timestamp").
+		Where("msr_id = ? AND timestamp >= ? AND path_id <> 0", msrID, since).
+		Find(&occurrences).Error; err != nil {
+		return occurrences, err
+	}
+	if err := database.DB.Model(&models.MeasurementResultPaths{}).
+		Select("path_id, timestamp").
+		Where("msr_id = ? AND timestamp >= ?", msrID, since).
+		Find(&multipathOccurrences).Error; err != nil {
+		return occurrences, err
+	}
+	return append(occurrences, multipathOccurrences...), nil
+}
+
+// Every path seen in the window with its hops, loaded in two queries
+func getPathsByID(occurrences []pathOccurrence) (map[uint]models.MeasurementPaths, error) {
+	paths := make(map[uint]models.MeasurementPaths)
+	var pathIDs []uint
+	listed := make(map[uint]bool)
+	for _, occurrence := range occurrences {
+		if !listed[occurrence.PathID] {
+			listed[occurrence.PathID] = true
+			pathIDs = append(pathIDs, occurrence.PathID)
+		}
+	}
+	if len(pathIDs) == 0 {
+		return paths, nil
+	}
+	var found []models.MeasurementPaths
+	if err := database.DB.Preload("Hops", func(db *gorm.DB) *gorm.DB {
+		return db.Order("hop_index")
+	}).Where("id IN ?", pathIDs).Find(&found).Error; err != nil {
+		return paths, err
+	}
+	for _, path := range found {
+		paths[path.ID] = path
+	}
+	return paths, nil
+}
+
+func GenerateTopologyGraph(msrID uuid.UUID, timeRange int) (map[string][]interface{}, error) {
+	graphData := make(map[string][]interface{})
+	if timeRange <= 0 {
+		return nil, errors.Errorf("Time range: %d is not supported, value should be a positive number of hours.", timeRange)
+	}
+	since := time.Now().Add(-time.Duration(timeRange) * time.Hour).Format(time.RFC3339)
+	occurrences, err := getPathOccurrences(msrID, since)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := getPathsByID(occurrences)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*topologyNode)
+	edges := make(map[string]*topologyEdge)
+	var nodeOrder, edgeOrder []string
+	for _, occurrence := range occurrences {
+		path, ok := paths[occurrence.PathID]
+		if !ok {
+			continue
+		}
+		// Silent hops are left out, their neighbours are linked across the gap
+		var previous []string
+		for _, group := range HopsByTTL(path.Hops) {
+			var current []string
+			for _, hop := range group {
+				if hop.IPAddress == TimeoutHop {
+					continue
+				}
+				node, ok := nodes[hop.IPAddress]
+				if !ok {
+					node = &topologyNode{ID: hop.IPAddress, ASN: hop.ASN, PTR: hop.PTR, Group: hop.ASN}
+					nodes[hop.IPAddress] = node
+					nodeOrder = append(nodeOrder, hop.IPAddress)
+				}
+				node.Seen++
+				if occurrence.Timestamp > node.LastSeen {
+					node.LastSeen = occurrence.Timestamp
+				}
+				for _, from := range previous {
+					key := from + ">" + hop.IPAddress
+					edge, ok := edges[key]
+					if !ok {
+						edge = &topologyEdge{From: from, To: hop.IPAddress}
+						edges[key] = edge
+						edgeOrder = append(edgeOrder, key)
+					}
+					edge.Value++
+					if occurrence.Timestamp > edge.LastSeen {
+						edge.LastSeen = occurrence.Timestamp
+					}
+				}
+				current = append(current, hop.IPAddress)
+			}
+			if len(current) > 0 {
+				previous = current
+			}
+		}
+	}
+	for _, id := range nodeOrder {
+		node := nodes[id]
+		node.Label = fmt.Sprintf("%s (%s)", node.ID, node.ASN)
+		if node.PTR != "" {
+			node.Label = fmt.Sprintf("%s\n%s", node.Label, node.PTR)
+		}
+		node.Title = fmt.Sprintf("Seen: %d, last seen: %s", node.Seen, node.LastSeen)
+		graphData["nodes"] = append(graphData["nodes"], node)
+	}
+	for _, key := range edgeOrder {
+		edge := edges[key]
+		edge.Label = fmt.Sprintf("%d", edge.Value)
+		edge.Title = fmt.Sprintf("Seen: %d, last seen: %s", edge.Value, edge.LastSeen)
+		graphData["edges"] = append(graphData["edges"], edge)
+	}
+	return graphData, nil
+}
@@ -2,11 +2,11 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -111,6 +111,11 @@ func UpdateMsrInDatabase(msrID string, stateChange string) (models.PingMeasureme
 		var msr models.PingMeasurement
 		var msrResults models.MeasurementResults
 		var msrAlerts models.MeasurementResultAlerts
+		var msrHopResults models.MeasurementHopResults
+		var msrResultPaths models.MeasurementResultPaths
+		var msrSamples models.MeasurementPacketSamples
+		var msrRules models.AlertRule
+		var msrEvents models.MeasurementEvent
 		if err := database.DB.Where("id = ?", msrID).Delete(&msr).Error; err != nil {
 			return msr, err
 		}
@@ -120,31 +125,42 @@ func UpdateMsrInDatabase(msrID string, stateChange string) (models.PingMeasureme
 		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrAlerts).Error; err != nil {
 			return msr, err
 		}
+		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrHopResults).Error; err != nil {
+			return msr, err
+		}
+		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrResultPaths).Error; err != nil {
+			return msr, err
+		}
+		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrSamples).Error; err != nil {
+			return msr, err
+		}
+		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrRules).Error; err != nil {
+			return msr, err
+		}
+		if err := database.DB.Where("msr_id = ?", msrID).Delete(&msrEvents).Error; err != nil {
+			return msr, err
+		}
+		if err := deleteMeasurementPaths(msrID); err != nil {
+			return msr, err
+		}
 	}
 	return msr, nil
 }
 
-func AddMsrToDatabase(target string, count, frequency int) (models.PingMeasurement, error) {
+func AddMsrToDatabase(data models.PingMeasurement) (models.PingMeasurement, error) {
 	log.Println("[i] 'AddMsrToDatabase' - Attempting to add measurement to the database.")
-	exists, err := checkIfTargetAlreadyExists(target)
+	exists, err := checkIfTargetAlreadyExists(data.Target)
 	if !exists {
-		var isHostname bool
-		if net.ParseIP(target) != nil {
-			isHostname = false
-		} else {
-			isHostname = false
-		}
-		data := models.PingMeasurement{
-			ID:          GenerateUUID(),
-			CreatedAt:   time.Now().Format(time.RFC3339),
-			Target:      target,
-			IsHostname:  isHostname,
-			Frequency:   frequency,
-			PacketCount: count,
-			Status:      StatusScheduled,
-			StatusName:  StatusNameScheduled,
-			LastPollAt:  "Never",
+		ApplyPingDefaults(&data)
+		if err := ValidatePingSettings(data); err != nil {
+			return models.PingMeasurement{}, err
 		}
+		data.ID = GenerateUUID()
+		data.CreatedAt = time.Now().Format(time.RFC3339)
+		data.IsHostname = net.ParseIP(data.Target) == nil
+		data.Status = StatusScheduled
+		data.StatusName = StatusNameScheduled
+		data.LastPollAt = "Never"
 		if err := database.DB.Create(&data).Error; err != nil {
 			log.Println("[!] 'AddMsrToDatabase' - There has been a problem with adding measurement to the database.", err)
 			return models.PingMeasurement{}, errors.Wrap(err, "Problem saving measurement to database.")
@@ -158,13 +174,56 @@ func AddMsrToDatabase(target string, count, frequency int) (models.PingMeasureme
 
 func GetPreviousMsrResult(msrID uuid.UUID) (models.MeasurementResults, error) {
 	var result models.MeasurementResults
-	if err := database.DB.Last(&result, "msr_id = ?", msrID).Error; err != nil {
+	if err := database.DB.Where("msr_id = ?", msrID).Order("timestamp desc").First(&result).Error; err != nil {
 		log.Println("[!] 'GetPreviousMsrResult' - There has been a problem finding previous results", err)
 		return result, err
 	}
 	return result, nil
 }
 
+// Latest result with a discovered path MTU, polls without discovery or where it failed have none
+func GetLatestPathMtuResult(msrID uuid.UUID) (models.MeasurementResults, error) {
+	var result models.MeasurementResults
+	if err := database.DB.Where("msr_id = ? AND path_mtu > 0", msrID).Order("timestamp desc").First(&result).Error; err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func GetLatestHopResults(msrID uuid.UUID) ([]models.MeasurementHopResults, error) {
+	var latest models.MeasurementHopResults
+	var hops []models.MeasurementHopResults
+	// Hop results have no primary key, Last would order by msr_id
+	if err := database.DB.Where("msr_id = ?", msrID).Order("timestamp desc").First(&latest).Error; err != nil {
+		return hops, err
+	}
+	if err := database.DB.Where("msr_id = ? AND timestamp = ?", msrID, latest.Timestamp).Order("hop").Find(&hops).Error; err != nil {
+		return hops, err
+	}
+	return hops, nil
+}
+
+func GetLatestPacketSamples(msrID uuid.UUID) ([]models.MeasurementPacketSamples, error) {
+	var latest models.MeasurementPacketSamples
+	var samples []models.MeasurementPacketSamples
+	// Samples have no primary key, Last would order by msr_id
+	if err := database.DB.Where("msr_id = ?", msrID).Order("timestamp desc").First(&latest).Error; err != nil {
+		return samples, err
+	}
+	if err := database.DB.Where("msr_id = ? AND timestamp = ?", msrID, latest.Timestamp).Order("seq").Find(&samples).Error; err != nil {
+		return samples, err
+	}
+	return samples, nil
+}
+
+func ConvertStringToBool(object string) bool {
+	boolObject, err := strconv.ParseBool(object)
+	if err != nil {
+		return object == "on"
+	}
+	return boolObject
+}
+
 func ConvertStringToInt(object string) int {
 	intObject, err := strconv.Atoi(object)
 	if err != nil {
@@ -184,9 +243,9 @@ func getResultsInTimeRange(results models.PingMeasurement, timeRange int) []mode
 	return results.Results[len(results.Results)-requestedNumberOfResults:]
 }
 
-func populateResultSlices(resultsInTimeRange []models.MeasurementResults) ([]RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData) {
+func populateResultSlices(resultsInTimeRange []models.MeasurementResults) ([]RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData, []RttData) {
 	var (
-		rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults []RttData
+		rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults, pathMtuResults, mosResults, rFactorResults []RttData
 	)
 	for _, result := range resultsInTimeRange {
 		timestamp, err := time.Parse(time.RFC3339, result.Timestamp)
@@ -203,8 +262,16 @@ func populateResultSlices(resultsInTimeRange []models.MeasurementResults) ([]Rtt
 		pktLossResults = append(pktLossResults, RttData{X: timestamp, Y: float64(result.Sent - result.Rcvd)})
 		ipHopCountResults = append(ipHopCountResults, RttData{X: timestamp, Y: float64(result.IPHopCount)})
 		asHopCountResults = append(asHopCountResults, RttData{X: timestamp, Y: float64(result.ASHopCount)})
+		if result.PathMtu > 0 {
+			pathMtuResults = append(pathMtuResults, RttData{X: timestamp, Y: float64(result.PathMtu)})
+		}
+		// Results stored before voice scoring have no MOS, the lowest score is 1
+		if result.MOS > 0 {
+			mosResults = append(mosResults, RttData{X: timestamp, Y: result.MOS})
+			rFactorResults = append(rFactorResults, RttData{X: timestamp, Y: result.RFactor})
+		}
 	}
-	return rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults
+	return rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults, pathMtuResults, mosResults, rFactorResults
 }
 
 func createResponseMap(name string, data []RttData) map[string]interface{} {
@@ -221,7 +288,7 @@ func GenerateCombinedChartData(msrID string, timeRange int) (map[string]map[stri
 		return data, err
 	}
 	resultsInTimeRange := getResultsInTimeRange(results, timeRange)
-	rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults := populateResultSlices(resultsInTimeRange)
+	rttMinResults, rttMaxResults, rttAvgResults, jitterResults, pktSentResults, pktRcvdResults, pktLossResults, ipHopCountResults, asHopCountResults, pathMtuResults, mosResults, rFactorResults := populateResultSlices(resultsInTimeRange)
 	data["Rtt"] = map[string]interface{}{
 		"Jitter":     createResponseMap("Jitter", jitterResults),
 		"LatencyAvg": createResponseMap("Latency (Avg)", rttAvgResults),
@@ -237,26 +304,39 @@ func GenerateCombinedChartData(msrID string, timeRange int) (map[string]map[stri
 		"IPHopCount": createResponseMap("IP Hops", ipHopCountResults),
 		"ASHopCount": createResponseMap("AS Hops", asHopCountResults),
 	}
+	data["Mtu"] = map[string]interface{}{
+		"PathMtu": createResponseMap("Path MTU", pathMtuResults),
+	}
+	data["Voice"] = map[string]interface{}{
+		"MOS":     createResponseMap("MOS", mosResults),
+		"RFactor": createResponseMap("R-Factor", rFactorResults),
+	}
 	return data, nil
 }
 
-func IPAddrLookupInfo(ipAddr string) (string, string, string, string) {
-	isp, asn, country, countryCode := "N/A", "N/A", "N/A", "N/A"
+func ipAddrLookupRemote(ipAddr string) (IPLookupData, error) {
+	ipInfo := IPLookupData{}
 	asnURL := "http://ip-api.com/json/" + ipAddr
 	resp, err := http.Get(asnURL)
 	if err != nil {
-		log.Println("[!] 'IPAddrLookupInfo' - Could not complete user IP lookup request:", err)
-		return "N/A", "N/A", "N/A", "N/A"
+		return ipInfo, errors.Wrap(err, "Could not complete IP lookup request.")
 	}
 	defer resp.Body.Close()
-	ipInfo := IPLookupData{}
 	if err := json.NewDecoder(resp.Body).Decode(&ipInfo); err != nil {
-		log.Println("[!] 'IPAddrLookupInfo' - Could not decode response from API:", err)
+		return ipInfo, errors.Wrap(err, "Could not decode response from API.")
+	}
+	if ipInfo.Status != "success" {
+		return ipInfo, errors.Errorf("Lookup for IP: %s returned status: %s", ipAddr, ipInfo.Status)
+	}
+	return ipInfo, nil
+}
+
+func IPAddrLookupInfo(ipAddr string) (string, string, string, string) {
+	ipInfo := cachedIPAddrLookup(ipAddr)
+	if ipInfo.Failed {
 		return "N/A", "N/A", "N/A", "N/A"
-	} else {
-		isp, asn, country, countryCode = ipInfo.Isp, ipInfo.As, ipInfo.Country, ipInfo.CountryCode
 	}
-	return isp, asn, country, countryCode
+	return ipInfo.ISP, ipInfo.ASN, ipInfo.Country, ipInfo.CountryCode
 }
 
 func GenerateVisitorsChart() []map[string]interface{} {
@@ -281,24 +361,54 @@ func GenerateVisitorsChart() []map[string]interface{} {
 func GenerateTracerouteGraph(msrID uuid.UUID) (map[string][]interface{}, error) {
 	nodeData := make(map[string][]interface{})
 	var edgeData []interface{}
-	previousPathTaken, err := GetPreviousMsrResult(msrID)
+	// Falls back to the last result with a path when the latest trace failed
+	previousPathTaken, err := GetLatestPathResult(msrID)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "No path has been recorded for this measurement.")
 	}
-	ipPathHops := strings.Split(previousPathTaken.CombinedPath, ">")
-	for index, ipHop := range ipPathHops {
-		hopData := map[string]any{
-			"id":    index,
-			"label": ipHop,
+	hopStats := make(map[string]models.MeasurementHopResults)
+	if hops, err := GetLatestHopResults(msrID); err == nil {
+		for _, hop := range hops {
+			if hop.Timestamp == previousPathTaken.Timestamp {
+				hopStats[hop.IPAddress] = hop
+			}
 		}
-		nodeData["nodes"] = append(nodeData["nodes"], hopData)
-		if index+1 < len(ipPathHops) {
-			edge := map[string]int{
-				"from": index,
-				"to":   index + 1,
+	}
+	path, err := GetMeasurementPath(previousPathTaken.PathID)
+	if err != nil {
+		return nil, errors.Wrap(err, "No path has been recorded for the latest result.")
+	}
+	// Every responder of a TTL links to every responder of the next one
+	var previous []int
+	for _, group := range HopsByTTL(path.Hops) {
+		var current []int
+		for _, hop := range group {
+			id := len(nodeData["nodes"])
+			label := fmt.Sprintf("%s (%s)", hop.IPAddress, hop.ASN)
+			if hop.IPAddress == TimeoutHop {
+				label = fmt.Sprintf("* TTL %d, no reply", hop.TTL)
+			}
+			if hop.PTR != "" {
+				label = fmt.Sprintf("%s\n%s", label, hop.PTR)
+			}
+			if stats, ok := hopStats[hop.IPAddress]; ok {
+				label = fmt.Sprintf("%s\nLoss: %.0f%% Avg: %.1fms", label, stats.Loss, stats.AvgRtt)
+			}
+			hopData := map[string]any{
+				"id":    id,
+				"label": label,
+			}
+			nodeData["nodes"] = append(nodeData["nodes"], hopData)
+			for _, from := range previous {
+				edge := map[string]int{
+					"from": from,
+					"to":   id,
+				}
+				edgeData = append(edgeData, edge)
 			}
-			edgeData = append(edgeData, edge)
+			current = append(current, id)
 		}
+		previous = current
 	}
 	nodeData["edges"] = edgeData
 	return nodeData, nil
@@ -0,0 +1,617 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&models.PingMeasurement{},
+		&models.MeasurementResults{},
+		&models.MeasurementResultAlerts{},
+		&models.MeasurementPacketSamples{},
+		&models.MeasurementHopResults{},
+		&models.MeasurementPaths{},
+		&models.MeasurementPathHops{},
+		&models.MeasurementResultPaths{},
+		&models.MeasurementPolicy{},
+		&models.DeniedPrefix{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+}
+
+// Timestamps are stored in the server's zone, tests run in one that is not UTC
+func withLocalZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestHistoryCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"round trip", encodeHistoryCursor("2024-01-01T10:00:00+02:00", 42), false},
+		{"empty timestamp", encodeHistoryCursor("", 1), false},
+		{"not base64", "%%%", true},
+		{"not json", "bm90IGpzb24", true},
+		{"no row id", encodeHistoryCursor("2024-01-01T10:00:00+02:00", 0), true},
+	}
+	for _, test := range tests {
+		cursor, err := decodeHistoryCursor(test.encoded)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if err == nil && encodeHistoryCursor(cursor.Timestamp, cursor.RowID) != test.encoded {
+			t.Errorf("%s: cursor %+v does not encode back to %s", test.name, cursor, test.encoded)
+		}
+	}
+}
+
+func TestGetMeasurementResultsWindow(t *testing.T) {
+	withLocalZone(t)
+	openTestDB(t)
+	msrID := uuid.New()
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		database.DB.Create(&models.MeasurementResults{MsrID: msrID, Timestamp: start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339)})
+	}
+	tests := []struct {
+		name    string
+		options HistoryOptions
+		want    int
+	}{
+		{"local offset", HistoryOptions{From: "2024-01-01T11:00:00+02:00", To: "2024-01-01T13:00:00+02:00"}, 3},
+		{"utc bounds", HistoryOptions{From: "2024-01-01T09:00:00Z", To: "2024-01-01T11:00:00Z"}, 3},
+		{"other offset", HistoryOptions{From: "2024-01-01T04:00:00-05:00"}, 4},
+		{"empty window", HistoryOptions{From: "2024-01-02T00:00:00Z"}, 0},
+		{"page boundary", HistoryOptions{Limit: 5}, 5},
+	}
+	for _, test := range tests {
+		records, next, err := GetMeasurementResults(msrID, test.options)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(records) != test.want {
+			t.Errorf("%s: got %d results, want %d", test.name, len(records), test.want)
+		}
+		if next != "" {
+			t.Errorf("%s: got a next cursor on the last page", test.name)
+		}
+	}
+	// Walking the pages returns every row once
+	seen, cursor := 0, ""
+	for page := 0; page < 5; page++ {
+		records, next, err := GetMeasurementResults(msrID, HistoryOptions{Limit: 2, Cursor: cursor, Sort: "timestamp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen += len(records)
+		if cursor = next; cursor == "" {
+			break
+		}
+	}
+	if seen != 5 {
+		t.Errorf("paging returned %d results, want 5", seen)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"single", []float64{7}, 99, 7},
+		{"median of even count", []float64{1, 2, 3, 4}, 50, 2.5},
+		{"interpolated", []float64{10, 20, 30, 40, 50}, 90, 46},
+		{"minimum", []float64{10, 20, 30}, 0, 10},
+		{"maximum", []float64{10, 20, 30}, 100, 30},
+	}
+	for _, test := range tests {
+		if got := percentile(test.sorted, test.p); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLossRuns(t *testing.T) {
+	samples := func(received ...bool) []models.MeasurementPacketSamples {
+		var samples []models.MeasurementPacketSamples
+		for i, ok := range received {
+			samples = append(samples, models.MeasurementPacketSamples{Seq: i, Received: ok})
+		}
+		return samples
+	}
+	tests := []struct {
+		name    string
+		samples []models.MeasurementPacketSamples
+		want    LossRunStats
+	}{
+		{"empty", nil, LossRunStats{Lengths: map[int]int{}}},
+		{"no loss", samples(true, true, true), LossRunStats{Lengths: map[int]int{}}},
+		{"all lost", samples(false, false, false), LossRunStats{Count: 1, Longest: 3, Average: 3, Lengths: map[int]int{3: 1}}},
+		{"runs", samples(false, true, false, false, true, false), LossRunStats{Count: 3, Longest: 2, Average: 4.0 / 3, Lengths: map[int]int{1: 2, 2: 1}}},
+	}
+	for _, test := range tests {
+		if got := lossRuns(test.samples); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRttHistogram(t *testing.T) {
+	tests := []struct {
+		name    string
+		sorted  []float64
+		buckets int
+		want    []HistogramBucket
+	}{
+		{"empty", nil, 4, []HistogramBucket{}},
+		{"single value", []float64{5, 5}, 4, []HistogramBucket{{From: 5, To: 5, Count: 2}}},
+		{"maximum in last bucket", []float64{0, 1, 2, 4}, 2, []HistogramBucket{{From: 0, To: 2, Count: 2}, {From: 2, To: 4, Count: 2}}},
+	}
+	for _, test := range tests {
+		if got := rttHistogram(test.sorted, test.buckets); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetMeasurementStatsWindow(t *testing.T) {
+	withLocalZone(t)
+	openTestDB(t)
+	msrID := uuid.New()
+	poll := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	for i, rtt := range []float64{10, 20, 0, 30} {
+		database.DB.Create(&models.MeasurementPacketSamples{MsrID: msrID, Timestamp: poll.Format(time.RFC3339), Seq: i, Received: rtt > 0, Rtt: rtt})
+	}
+	lost := poll.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		database.DB.Create(&models.MeasurementPacketSamples{MsrID: msrID, Timestamp: lost.Format(time.RFC3339), Seq: i})
+	}
+	tests := []struct {
+		name       string
+		from, to   time.Time
+		sent, rcvd int
+		loss       float64
+	}{
+		{"utc bounds", poll.UTC().Add(-time.Minute), poll.UTC().Add(time.Minute), 4, 3, 25},
+		{"all lost", lost.UTC().Add(-time.Minute), lost.UTC().Add(time.Minute), 2, 0, 100},
+		{"empty", poll.Add(-2 * time.Hour), poll.Add(-time.Hour), 0, 0, 0},
+	}
+	for _, test := range tests {
+		stats, err := GetMeasurementStats(msrID, test.from, test.to, DefaultHistogramBuckets)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if stats.Sent != test.sent || stats.Rcvd != test.rcvd || stats.Loss != test.loss {
+			t.Errorf("%s: got %d sent %d received %v%% loss, want %d %d %v%%", test.name, stats.Sent, stats.Rcvd, stats.Loss, test.sent, test.rcvd, test.loss)
+		}
+	}
+}
+
+func TestPollJitter(t *testing.T) {
+	tests := []struct {
+		name  string
+		polls [][]float64
+		want  float64
+	}{
+		{"no polls", nil, 0},
+		{"single reply", [][]float64{{10}}, 0},
+		{"steady", [][]float64{{10, 10, 10}}, 0},
+		{"one poll", [][]float64{{10, 14, 12}}, 0.359375},
+		{"pairs do not span polls", [][]float64{{10, 12}, {50, 54}}, 0.3671875},
+		{"all lost poll", [][]float64{{10, 12}, nil}, 0.125},
+	}
+	for _, test := range tests {
+		if got := PollJitter(test.polls); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSampleJitter(t *testing.T) {
+	samples := []models.MeasurementPacketSamples{
+		{Timestamp: "a", Seq: 0, Received: true, Rtt: 10},
+		{Timestamp: "a", Seq: 1},
+		{Timestamp: "a", Seq: 2, Received: true, Rtt: 14},
+		{Timestamp: "b", Seq: 0, Received: true, Rtt: 50},
+		{Timestamp: "b", Seq: 1, Received: true, Rtt: 52},
+	}
+	if got := sampleJitter(samples); got != 0.359375 {
+		t.Errorf("got %v, want 0.359375", got)
+	}
+}
+
+func TestPathHash(t *testing.T) {
+	hop := func(ttl int, ip string) models.MeasurementPathHops {
+		return models.MeasurementPathHops{TTL: ttl, IPAddress: ip}
+	}
+	base := []models.MeasurementPathHops{hop(1, "10.0.0.1"), hop(2, "192.0.2.1"), hop(3, "198.51.100.1")}
+	tests := []struct {
+		name string
+		hops []models.MeasurementPathHops
+		same bool
+	}{
+		{"identical", []models.MeasurementPathHops{hop(1, "10.0.0.1"), hop(2, "192.0.2.1"), hop(3, "198.51.100.1")}, true},
+		{"timeout", []models.MeasurementPathHops{hop(1, "10.0.0.1"), hop(2, TimeoutHop), hop(3, "198.51.100.1")}, false},
+		{"ecmp responder at the same ttl", []models.MeasurementPathHops{hop(1, "10.0.0.1"), hop(2, "192.0.2.1"), hop(2, "192.0.2.2"), hop(3, "198.51.100.1")}, false},
+		{"same addresses at other ttls", []models.MeasurementPathHops{hop(1, "10.0.0.1"), hop(3, "192.0.2.1"), hop(4, "198.51.100.1")}, false},
+	}
+	for _, test := range tests {
+		if same := pathHash(test.hops) == pathHash(base); same != test.same {
+			t.Errorf("%s: same hash %v, want %v", test.name, same, test.same)
+		}
+	}
+}
+
+func TestPathSummary(t *testing.T) {
+	hops := []models.MeasurementPathHops{
+		{TTL: 1, IPAddress: "10.0.0.1", ASN: "Private"},
+		{TTL: 2, IPAddress: TimeoutHop},
+		{TTL: 3, IPAddress: "1.1.1.1", ASN: "AS13335"},
+		{TTL: 3, IPAddress: "1.0.0.1", ASN: "AS13335"},
+		{TTL: 4, IPAddress: "8.8.8.8", ASN: "AS15169"},
+	}
+	asPath, ipPath := PathSummary(hops)
+	if asPath != "AS13335 > AS15169" {
+		t.Errorf("got AS path %s", asPath)
+	}
+	if ipPath != "10.0.0.1 > * > 1.1.1.1|1.0.0.1 > 8.8.8.8" {
+		t.Errorf("got IP path %s", ipPath)
+	}
+}
+
+func TestMigrateResultPaths(t *testing.T) {
+	openTestDB(t)
+	for _, column := range []string{"ip_path", "as_path", "combined_path"} {
+		if err := database.DB.Exec("ALTER TABLE `measurement_results` ADD `" + column + "` text").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	msrID := uuid.New()
+	database.DB.Exec("INSERT INTO measurement_results (msr_id, timestamp, path_id, combined_path) VALUES (?, ?, 0, ?)",
+		msrID, "2024-01-01T10:00:00Z", "10.0.0.1 (Private) > 192.0.2.1 [core.example.net] (AS64500)")
+	MigrateResultPaths()
+	if database.DB.Migrator().HasColumn(&models.MeasurementResults{}, "combined_path") {
+		t.Error("combined_path column was not dropped")
+	}
+	result, err := GetLatestPathResult(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := GetMeasurementPath(result.PathID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.MeasurementPathHops{
+		{PathID: path.ID, HopIndex: 0, TTL: 1, IPAddress: "10.0.0.1", ASN: "Private"},
+		{PathID: path.ID, HopIndex: 1, TTL: 2, IPAddress: "192.0.2.1", ASN: "AS64500", PTR: "core.example.net"},
+	}
+	if !reflect.DeepEqual(path.Hops, want) {
+		t.Errorf("got hops %+v", path.Hops)
+	}
+}
+
+func TestMigrateResultPathsKeepsColumnsOnFailure(t *testing.T) {
+	openTestDB(t)
+	for _, column := range []string{"ip_path", "as_path", "combined_path"} {
+		if err := database.DB.Exec("ALTER TABLE `measurement_results` ADD `" + column + "` text").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	msrID := uuid.New()
+	for _, combinedPath := range []string{"10.0.0.1 (Private) > 1.1.1.1 (AS13335)", "not a path"} {
+		database.DB.Exec("INSERT INTO measurement_results (msr_id, timestamp, path_id, combined_path) VALUES (?, ?, 0, ?)",
+			msrID, "2024-01-01T10:00:00Z", combinedPath)
+	}
+	MigrateResultPaths()
+	if !database.DB.Migrator().HasColumn(&models.MeasurementResults{}, "combined_path") {
+		t.Fatal("combined_path column was dropped with results left to migrate")
+	}
+	var migrated int64
+	database.DB.Model(&models.MeasurementResults{}).Where("path_id <> 0").Count(&migrated)
+	if migrated != 0 {
+		t.Errorf("%d results were migrated outside the transaction", migrated)
+	}
+}
+
+func TestGenerateTracerouteGraph(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	if _, err := GenerateTracerouteGraph(msrID); err == nil {
+		t.Error("graph of a measurement without paths did not fail")
+	}
+	path, err := SaveMeasurementPath(msrID, []models.MeasurementPathHops{
+		{TTL: 1, IPAddress: "10.0.0.1"},
+		{TTL: 2, IPAddress: "192.0.2.1"},
+		{TTL: 2, IPAddress: "192.0.2.2"},
+		{TTL: 3, IPAddress: TimeoutHop},
+		{TTL: 4, IPAddress: "198.51.100.1"},
+	}, "2024-01-01T10:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Create(&models.MeasurementResults{MsrID: msrID, Timestamp: "2024-01-01T10:00:00Z", PathID: path.ID})
+	// The latest trace failed, the graph shows the last known path
+	database.DB.Create(&models.MeasurementResults{MsrID: msrID, Timestamp: "2024-01-01T10:05:00Z"})
+	graph, err := GenerateTracerouteGraph(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph["nodes"]) != 5 || len(graph["edges"]) != 5 {
+		t.Errorf("got %d nodes and %d edges, want 5 and 5", len(graph["nodes"]), len(graph["edges"]))
+	}
+}
+
+func TestGenerateTopologyGraph(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	now := time.Now()
+	hops := [][]models.MeasurementPathHops{
+		{{TTL: 1, IPAddress: "10.0.0.1"}, {TTL: 2, IPAddress: "1.1.1.1"}, {TTL: 3, IPAddress: "8.8.8.8"}},
+		{{TTL: 1, IPAddress: "10.0.0.1"}, {TTL: 2, IPAddress: TimeoutHop}, {TTL: 3, IPAddress: "8.8.8.8"}},
+	}
+	for i, pathHops := range hops {
+		timestamp := now.Add(-time.Duration(i) * time.Minute).Format(time.RFC3339)
+		path, err := SaveMeasurementPath(msrID, pathHops, timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		database.DB.Create(&models.MeasurementResults{MsrID: msrID, Timestamp: timestamp, PathID: path.ID})
+		database.DB.Create(&models.MeasurementResultPaths{MsrID: msrID, Timestamp: timestamp, PathID: path.ID, FlowID: 3})
+	}
+	tests := []struct {
+		name      string
+		timeRange int
+		nodes     int
+		edges     int
+		wantErr   bool
+	}{
+		{"window", 1, 3, 3, false},
+		{"zero", 0, 0, 0, true},
+		{"negative", -1, 0, 0, true},
+	}
+	for _, test := range tests {
+		graph, err := GenerateTopologyGraph(msrID, test.timeRange)
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if len(graph["nodes"]) != test.nodes || len(graph["edges"]) != test.edges {
+			t.Errorf("%s: got %d nodes and %d edges, want %d and %d", test.name, len(graph["nodes"]), len(graph["edges"]), test.nodes, test.edges)
+		}
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	msr := models.PingMeasurement{ID: uuid.New(), Target: "1.1.1.1", Frequency: 5, CreatedAt: "2024-01-01T10:00:00+02:00"}
+	tests := []struct {
+		name    string
+		encoded string
+		value   any
+		wantErr bool
+	}{
+		{"string column", encodeListCursor(msr, "target"), "1.1.1.1", false},
+		{"number column", encodeListCursor(msr, "frequency"), float64(5), false},
+		{"empty value", encodeListCursor(msr, "last_poll_at"), "", false},
+		{"not base64", "***", nil, true},
+		{"no id", "eyJ2IjoxfQ", nil, true},
+	}
+	for _, test := range tests {
+		cursor, err := decodeListCursor(test.encoded)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && (cursor.Value != test.value || cursor.ID != msr.ID.String()) {
+			t.Errorf("%s: got %+v", test.name, cursor)
+		}
+	}
+}
+
+func TestListMeasurementsPages(t *testing.T) {
+	openTestDB(t)
+	// Equal frequencies make the id break the tie between pages
+	for i := 0; i < 4; i++ {
+		database.DB.Create(&models.PingMeasurement{ID: uuid.New(), Target: fmt.Sprintf("1.1.1.%d", i+1), Frequency: 5 + i/2})
+	}
+	tests := []struct {
+		name  string
+		limit int
+		sort  string
+		pages []int
+	}{
+		{"page boundary", 4, "frequency", []int{4}},
+		{"ties across pages", 1, "frequency", []int{1, 1, 1, 1}},
+		{"last page is short", 3, "-frequency", []int{3, 1}},
+	}
+	for _, test := range tests {
+		var pages []int
+		seen := map[uuid.UUID]bool{}
+		cursor := ""
+		for len(pages) < 10 {
+			summaries, next, err := ListMeasurements(database.DB.Model(&models.PingMeasurement{}), ListOptions{Limit: test.limit, Sort: test.sort, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			pages = append(pages, len(summaries))
+			for _, summary := range summaries {
+				if seen[summary.ID] {
+					t.Errorf("%s: measurement %s listed twice", test.name, summary.ID)
+				}
+				seen[summary.ID] = true
+			}
+			if cursor = next; cursor == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(pages, test.pages) {
+			t.Errorf("%s: got pages %v, want %v", test.name, pages, test.pages)
+		}
+	}
+}
+
+func TestVoiceQuality(t *testing.T) {
+	tests := []struct {
+		name                 string
+		latency, jitter      float64
+		loss                 float64
+		wantRFactor, wantMOS float64
+	}{
+		{"zero delay", 0, 0, 0, 92.95, 4.404},
+		{"delay knee", 150, 0, 0, 89.2, 4.319},
+		{"jitter counts twice", 0, 75, 0, 89.2, 4.319},
+		{"past the knee", 250, 0, 0, 79.2, 3.993},
+		{"all lost", 20, 0, 100, 0, 1},
+		{"mos clamped on long delay", 1000, 0, 0, 4.2, 1},
+	}
+	for _, test := range tests {
+		rFactor, mos := VoiceQuality(test.latency, test.jitter, test.loss)
+		if math.Abs(rFactor-test.wantRFactor) > 0.005 || math.Abs(mos-test.wantMOS) > 0.005 {
+			t.Errorf("%s: got R %v MOS %v, want R %v MOS %v", test.name, rFactor, mos, test.wantRFactor, test.wantMOS)
+		}
+	}
+}
+
+func TestGetLatestHopResults(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	polls := []struct {
+		timestamp string
+		hops      []string
+	}{
+		{"2024-01-01T10:00:00+02:00", []string{"1.0.0.1"}},
+		{"2024-01-01T10:05:00+02:00", []string{"1.1.1.1", "8.8.8.8"}},
+	}
+	for _, poll := range polls {
+		for hop, ip := range poll.hops {
+			database.DB.Create(&models.MeasurementHopResults{MsrID: msrID, Timestamp: poll.timestamp, Hop: hop + 1, IPAddress: ip})
+		}
+	}
+	hops, err := GetLatestHopResults(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 2 || hops[0].Timestamp != polls[1].timestamp || hops[1].IPAddress != "8.8.8.8" {
+		t.Errorf("got %+v", hops)
+	}
+}
+
+func TestGetLatestMultipathSet(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	polls := []struct {
+		timestamp string
+		paths     [][]string
+	}{
+		{"2024-01-01T10:00:00+02:00", [][]string{{"1.0.0.1"}}},
+		{"2024-01-01T10:05:00+02:00", [][]string{{"1.1.1.1"}, {"8.8.8.8"}}},
+	}
+	for _, poll := range polls {
+		for flow, ips := range poll.paths {
+			var hops []models.MeasurementPathHops
+			for ttl, ip := range ips {
+				hops = append(hops, models.MeasurementPathHops{TTL: ttl + 1, IPAddress: ip})
+			}
+			path, err := SaveMeasurementPath(msrID, hops, poll.timestamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			database.DB.Create(&models.MeasurementResultPaths{MsrID: msrID, Timestamp: poll.timestamp, PathID: path.ID, FlowID: flow + 1})
+		}
+	}
+	paths, err := GetLatestMultipathSet(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("got %d paths, want the 2 of the latest poll", len(paths))
+	}
+}
+
+func TestCheckMeasurementPolicy(t *testing.T) {
+	openTestDB(t)
+	existing := models.PingMeasurement{ID: uuid.New(), Target: "127.0.0.1", Frequency: 1, PacketCount: 10}
+	database.DB.Create(&existing)
+	changed := existing
+	changed.Frequency = 2
+	tests := []struct {
+		name    string
+		msr     models.PingMeasurement
+		wantErr bool
+	}{
+		{"new public target", models.PingMeasurement{Target: "1.1.1.1", Frequency: 5}, false},
+		{"new private target", models.PingMeasurement{Target: "10.0.0.1", Frequency: 5}, false},
+		{"new below minimum frequency", models.PingMeasurement{Target: "1.1.1.1", Frequency: 1}, true},
+		{"new loopback target", models.PingMeasurement{Target: "127.0.0.1", Frequency: 5}, true},
+		{"new link-local target", models.PingMeasurement{Target: "169.254.1.1", Frequency: 5}, true},
+		{"new multicast target", models.PingMeasurement{Target: "224.0.0.1", Frequency: 5}, true},
+		{"existing keeps its frequency and target", existing, false},
+		{"existing frequency change below minimum", changed, true},
+	}
+	for _, test := range tests {
+		if err := CheckMeasurementPolicy(test.msr, false); (err != nil) != test.wantErr {
+			t.Errorf("%s: got %v, want error %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestGetLatestPathMtuResult(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	for _, result := range []models.MeasurementResults{
+		{MsrID: msrID, Timestamp: "2024-01-01T10:00:00+02:00", PathMtu: 1500},
+		{MsrID: msrID, Timestamp: "2024-01-01T10:05:00+02:00", PathMtu: 1400},
+		{MsrID: msrID, Timestamp: "2024-01-01T10:10:00+02:00"},
+	} {
+		database.DB.Create(&result)
+	}
+	result, err := GetLatestPathMtuResult(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PathMtu != 1400 {
+		t.Errorf("got path MTU %d of %s", result.PathMtu, result.Timestamp)
+	}
+}
+
+func TestGetLatestPacketSamples(t *testing.T) {
+	openTestDB(t)
+	msrID := uuid.New()
+	for _, sample := range []models.MeasurementPacketSamples{
+		{MsrID: msrID, Timestamp: "2024-01-01T10:00:00+02:00", Seq: 0, Received: true, Rtt: 10},
+		{MsrID: msrID, Timestamp: "2024-01-01T10:05:00+02:00", Seq: 1, Received: true, Rtt: 12},
+		{MsrID: msrID, Timestamp: "2024-01-01T10:05:00+02:00", Seq: 0, Received: true, Rtt: 11},
+	} {
+		database.DB.Create(&sample)
+	}
+	samples, err := GetLatestPacketSamples(msrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Seq != 0 || samples[0].Rtt != 11 {
+		t.Errorf("got %+v", samples)
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+// E-model, simplified ITU-T G.107 for G.711 without packet loss concealment
+const (
+	voiceCodecDelay   = 10
+	voiceBaseRFactor  = 93.2
+	voiceLossPenalty  = 2.5
+	voiceDelayKnee    = 160
+	voiceMaxMOS       = 4.5
+	voiceMinMOS       = 1
+	voiceMaxRFactor   = 100
+	voiceMinRFactor   = 0
+	voiceJitterFactor = 2
+)
+
+func VoiceQuality(latency, jitter, loss float64) (float64, float64) {
+	// Jitter buffers add roughly twice the jitter on top of the one-way delay
+	effectiveLatency := latency + voiceJitterFactor*jitter + voiceCodecDelay
+	var rFactor float64
+	if effectiveLatency < voiceDelayKnee {
+		rFactor = voiceBaseRFactor - effectiveLatency/40
+	} else {
+		rFactor = voiceBaseRFactor - (effectiveLatency-120)/10
+	}
+	rFactor -= voiceLossPenalty * loss
+	if rFactor < voiceMinRFactor {
+		rFactor = voiceMinRFactor
+	} else if rFactor > voiceMaxRFactor {
+		rFactor = voiceMaxRFactor
+	}
+	mos := voiceMinMOS + 0.035*rFactor + 0.000007*rFactor*(rFactor-60)*(100-rFactor)
+	if mos < voiceMinMOS {
+		mos = voiceMinMOS
+	} else if mos > voiceMaxMOS {
+		mos = voiceMaxMOS
+	}
+	return rFactor, mos
+}
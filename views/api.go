@@ -1,15 +1,22 @@
 package views
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/audit"
+	"github.com/sngx13/pingernoid/auth"
 	"github.com/sngx13/pingernoid/database"
 	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/reports"
 	"github.com/sngx13/pingernoid/scheduler"
 	"github.com/sngx13/pingernoid/utils"
 )
@@ -22,50 +29,174 @@ type AlertDetails struct {
 	AlertingIPPath string  `json:"alerting_ip_path"`
 	ExpectedASPath string  `json:"expected_as_path"`
 	ExpectedIPPath string  `json:"expected_ip_path"`
+	PathDiff       any     `json:"path_diff"`
 }
 
 type requestData struct {
-	Target      string `json:"target"`
-	PacketCount string `json:"packet_count"`
-	Frequency   string `json:"frequency"`
+	Target      string    `json:"target" binding:"required,ip"`
+	PacketCount IntField  `json:"packet_count" binding:"required,min=1,max=100"`
+	Frequency   IntField  `json:"frequency" binding:"required,min=1"`
+	MtrMode     BoolField `json:"mtr_mode"`
+	Multipath   BoolField `json:"multipath"`
+	PmtuMode    BoolField `json:"pmtu_mode"`
+	PacketSize  IntField  `json:"packet_size" binding:"omitempty,min=24,max=1472"`
+	Interval    IntField  `json:"interval" binding:"omitempty,min=100,max=10000"`
+	TTL         IntField  `json:"ttl" binding:"omitempty,min=1,max=255"`
+	Timeout     IntField  `json:"timeout" binding:"omitempty,min=1,max=600"`
+	DSCP        IntField  `json:"dscp" binding:"omitempty,min=0,max=63"`
+	Tags        string    `json:"tags"`
+}
+
+type updateRequestData struct {
+	PacketCount     IntField   `json:"packet_count" binding:"omitempty,min=1,max=100"`
+	Frequency       IntField   `json:"frequency" binding:"omitempty,min=1"`
+	PacketSize      IntField   `json:"packet_size" binding:"omitempty,min=24,max=1472"`
+	Interval        IntField   `json:"interval" binding:"omitempty,min=100,max=10000"`
+	TTL             IntField   `json:"ttl" binding:"omitempty,min=1,max=255"`
+	Timeout         IntField   `json:"timeout" binding:"omitempty,min=1,max=600"`
+	DSCP            IntField   `json:"dscp" binding:"omitempty,min=0,max=63"`
+	MtrMode         BoolField  `json:"mtr_mode"`
+	Multipath       BoolField  `json:"multipath"`
+	PmtuMode        BoolField  `json:"pmtu_mode"`
+	ThresholdMinRtt FloatField `json:"threshold_min_rtt" binding:"omitempty,gt=0"`
+	ThresholdMaxRtt FloatField `json:"threshold_max_rtt" binding:"omitempty,gt=0"`
+	ThresholdAvgRtt FloatField `json:"threshold_avg_rtt" binding:"omitempty,gt=0"`
+	ThresholdJitter FloatField `json:"threshold_jitter" binding:"omitempty,gt=0"`
+	ThresholdLoss   FloatField `json:"threshold_loss" binding:"omitempty,min=0,lt=100"`
+	// Tags are replaced as a whole, an empty string removes them
+	Tags *string `json:"tags"`
+}
+
+type ruleRequestData struct {
+	Metric    string     `json:"metric" binding:"required,oneof=avg_rtt max_rtt p50_rtt p90_rtt p95_rtt p99_rtt jitter mos r_factor loss loss_run loss_run_count"`
+	Operator  string     `json:"operator" binding:"required,oneof=gt gte lt lte"`
+	Threshold FloatField `json:"threshold" binding:"required"`
+	Window    IntField   `json:"window" binding:"omitempty,min=1,max=10080"`
+}
+
+type scheduleRequestData struct {
+	Name        string     `json:"name" binding:"required"`
+	Period      string     `json:"period" binding:"required,oneof=week month"`
+	MsrIDs      string     `json:"msr_ids"`
+	Channel     string     `json:"channel" binding:"required,oneof=email webhook"`
+	Destination string     `json:"destination" binding:"required"`
+	SlaRtt      FloatField `json:"sla_rtt" binding:"omitempty,gt=0"`
+	SlaLoss     FloatField `json:"sla_loss" binding:"omitempty,min=0,max=100"`
+}
+
+type passwordRequestData struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=72"`
+}
+
+type userRequestData struct {
+	Username string   `json:"username" binding:"required,min=3,max=32"`
+	Password string   `json:"password" binding:"required,min=8,max=72"`
+	Role     string   `json:"role" binding:"omitempty,oneof=viewer operator admin"`
+	TeamID   IntField `json:"team_id" binding:"omitempty,min=0"`
+}
+
+type userAccessRequestData struct {
+	Role   string   `json:"role" binding:"required,oneof=viewer operator admin"`
+	TeamID IntField `json:"team_id" binding:"omitempty,min=0"`
+}
+
+type teamRequestData struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type policyRequestData struct {
+	MinFrequency           IntField `json:"min_frequency" binding:"omitempty,min=1"`
+	MaxPacketCount         IntField `json:"max_packet_count" binding:"omitempty,min=1,max=100"`
+	MaxMeasurementsPerUser IntField `json:"max_measurements_per_user" binding:"omitempty,min=0"`
+}
+
+type deniedPrefixRequestData struct {
+	Prefix string `json:"prefix" binding:"required,ip|cidr"`
+	Reason string `json:"reason"`
+}
+
+type tokenRequestData struct {
+	Name       string   `json:"name" binding:"required"`
+	Scope      string   `json:"scope" binding:"required,oneof=read write"`
+	ExpiryDays IntField `json:"expiry_days" binding:"omitempty,min=0,max=365"`
 }
 
 func ApiGetMeasurements(c *gin.Context) {
-	var msrs []models.PingMeasurement
-	if err := database.DB.Preload("Results").Preload("Alerts").Find(&msrs).Error; err != nil {
+	user, _ := auth.CurrentUser(c)
+	limit, ok := queryInt(c, "limit")
+	if !ok {
+		return
+	}
+	data, nextCursor, err := utils.ListMeasurements(auth.VisibleMeasurements(database.DB.Model(&models.PingMeasurement{}), user), utils.ListOptions{
+		Limit:        limit,
+		Cursor:       c.Query("cursor"),
+		Status:       c.Query("status"),
+		TargetPrefix: c.Query("target"),
+		Tag:          c.Query("tag"),
+		Alerting:     c.Query("alerting"),
+		Sort:         c.Query("sort"),
+	})
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
 	}
 	c.IndentedJSON(http.StatusOK, gin.H{
-		"status": http.StatusOK,
-		"data":   msrs,
+		"status":      http.StatusOK,
+		"data":        data,
+		"next_cursor": nextCursor,
 	})
 }
 
+func resultPathSummary(result models.MeasurementResults) (string, string) {
+	path, err := utils.GetMeasurementPath(result.PathID)
+	if err != nil {
+		return "", ""
+	}
+	return utils.PathSummary(path.Hops)
+}
+
+// Malformed IDs are answered like any other bad request instead of panicking the handler
+func parseMsrID(c *gin.Context) (uuid.UUID, bool) {
+	msrID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Measurement ID: '%s' is not a valid UUID.", c.Param("id"))})
+		return msrID, false
+	}
+	return msrID, true
+}
+
 func ApiGetAlertDetails(c *gin.Context) {
 	msrID := c.Param("id")
 	timestamp := c.Param("timestamp")
 	var msrWithAlert models.PingMeasurement
-	var msrLatest models.PingMeasurement
 	if err := database.DB.Preload("Results").Where("id = ?", msrID).First(&msrWithAlert).Error; err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
 	}
-	if err := database.DB.Preload("Results").Where("id = ?", msrID).Last(&msrLatest).Error; err != nil {
-		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
-		return
+	var expectedASPath, expectedIPPath string
+	if latest, err := utils.GetLatestPathResult(msrWithAlert.ID); err == nil {
+		expectedASPath, expectedIPPath = resultPathSummary(latest)
+	}
+	var pathDiff any
+	var alert models.MeasurementResultAlerts
+	if err := database.DB.Where("msr_id = ? AND alert_timestamp = ? AND alert_reason IN ? AND alert_details <> ''", msrID, timestamp, []string{"AS_PATH_CHANGE", "IP_PATH_CHANGE"}).First(&alert).Error; err == nil {
+		if err := json.Unmarshal([]byte(alert.AlertDetails), &pathDiff); err != nil {
+			pathDiff = nil
+		}
 	}
-	latestAsPath := msrLatest.Results[len(msrLatest.Results)-1]
 	for _, info := range msrWithAlert.Results {
 		if info.Timestamp == timestamp {
+			alertingASPath, alertingIPPath := resultPathSummary(info)
 			pathsData := AlertDetails{
 				AvgRtt:         info.AvgRtt,
 				Jitter:         info.Jitter,
 				Loss:           info.Loss,
-				AlertingASPath: info.ASPath,
-				AlertingIPPath: info.IPPath,
-				ExpectedASPath: latestAsPath.ASPath,
-				ExpectedIPPath: latestAsPath.IPPath,
+				AlertingASPath: alertingASPath,
+				AlertingIPPath: alertingIPPath,
+				ExpectedASPath: expectedASPath,
+				ExpectedIPPath: expectedIPPath,
+				PathDiff:       pathDiff,
 			}
 			c.IndentedJSON(http.StatusOK, gin.H{
 				"status": http.StatusOK,
@@ -90,9 +221,157 @@ func ApiGetMeasurement(c *gin.Context) {
 	})
 }
 
+func historyOptions(c *gin.Context) (utils.HistoryOptions, bool) {
+	limit, ok := queryInt(c, "limit")
+	return utils.HistoryOptions{
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+		Fields: c.Query("fields"),
+		Sort:   c.Query("sort"),
+	}, ok
+}
+
+func ApiGetMeasurementResults(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	options, ok := historyOptions(c)
+	if !ok {
+		return
+	}
+	data, nextCursor, err := utils.GetMeasurementResults(msrID, options)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":      http.StatusOK,
+		"data":        data,
+		"next_cursor": nextCursor,
+	})
+}
+
+func ApiGetMeasurementAlerts(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	options, ok := historyOptions(c)
+	if !ok {
+		return
+	}
+	data, nextCursor, err := utils.GetMeasurementAlerts(msrID, options)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":      http.StatusOK,
+		"data":        data,
+		"next_cursor": nextCursor,
+	})
+}
+
 func ApiGetMeasurementTracePathGraph(c *gin.Context) {
-	msrID := c.Param("id")
-	data, err := utils.GenerateTracerouteGraph(uuid.MustParse(msrID))
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GenerateTracerouteGraph(msrID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementTopologyGraph(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	timeRange, err := strconv.Atoi(c.Param("time_range"))
+	if err != nil || timeRange <= 0 {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert timeRange to a positive integer"})
+		return
+	}
+	data, err := utils.GenerateTopologyGraph(msrID, timeRange)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementTraceHops(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GetLatestHopResults(msrID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementSamples(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GetLatestPacketSamples(msrID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementPaths(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert days to a positive integer"})
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	data, err := utils.GetMeasurementPaths(msrID, since)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementMultipath(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GetLatestMultipathSet(msrID)
 	if err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
@@ -113,9 +392,11 @@ func ApiGetMeasurementCombinedChartResults(c *gin.Context) {
 	data, err := utils.GenerateCombinedChartData(msrID, timeRange)
 	if err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{
-			"status": http.StatusBadRequest,
-			"data":   data,
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("Error: %s", err),
+			"data":    data,
 		})
+		return
 	}
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"status": http.StatusOK,
@@ -124,12 +405,17 @@ func ApiGetMeasurementCombinedChartResults(c *gin.Context) {
 }
 
 func ApiDeleteMeasurement(c *gin.Context) {
-	msrID := c.Param("id")
-	_, err := utils.UpdateMsrInDatabase(msrID, utils.StatusNameDelete)
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	_, err := utils.UpdateMsrInDatabase(msrID.String(), utils.StatusNameDelete)
 	if err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
 	}
+	scheduler.UnschedulePingMeasurement(msrID)
+	audit.Record(c, audit.ActionDelete, audit.ObjectMeasurement, msrID.String(), c.MustGet("measurement"), nil)
 	c.Header("HX-Trigger", "reloadTable")
 	message := fmt.Sprintf("Measurement: %s was deleted.", msrID)
 	c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNoContent, "message": message})
@@ -142,6 +428,8 @@ func ApiStopMeasurement(c *gin.Context) {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
 	}
+	audit.Record(c, audit.ActionStop, audit.ObjectMeasurement, msrID, c.MustGet("measurement"), msr)
+	utils.AddMeasurementEvent(msr.ID, utils.EventStopped, c.GetString("username"), "Measurement was stopped", nil)
 	c.Header("HX-Trigger", "reloadTable")
 	message := fmt.Sprintf("Measurement: %s was stopped.", msrID)
 	c.IndentedJSON(http.StatusOK, gin.H{
@@ -153,11 +441,20 @@ func ApiStopMeasurement(c *gin.Context) {
 
 func ApiRestartMeasurement(c *gin.Context) {
 	msrID := c.Param("id")
+	// The policy may have been tightened since the measurement was created
+	if err := utils.CheckMeasurementPolicy(c.MustGet("measurement").(models.PingMeasurement), false); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
 	msr, err := utils.UpdateMsrInDatabase(msrID, utils.StatusNameRestarting)
 	if err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
 		return
 	}
+	// Measurements stopped before a program restart have no job left to pick the new status up
+	scheduler.SchedulePingMeasurement(msr.ID, msr.Target, msr.PacketCount, msr.Frequency)
+	audit.Record(c, audit.ActionRestart, audit.ObjectMeasurement, msrID, c.MustGet("measurement"), msr)
+	utils.AddMeasurementEvent(msr.ID, utils.EventRestarted, c.GetString("username"), "Measurement was restarted", nil)
 	c.Header("HX-Trigger", "reloadTable")
 	message := fmt.Sprintf("Measurement: %s was restarted.", msrID)
 	c.IndentedJSON(http.StatusOK, gin.H{
@@ -168,40 +465,162 @@ func ApiRestartMeasurement(c *gin.Context) {
 }
 
 func ApiCreateMeasurement(c *gin.Context) {
+	user, _ := auth.CurrentUser(c)
 	var requestData requestData
-	if err := c.BindJSON(&requestData); err != nil {
-		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+	if !bindRequest(c, &requestData) {
 		return
 	}
-	frequency := utils.ConvertStringToInt(requestData.Frequency)
-	packetCount := utils.ConvertStringToInt(requestData.PacketCount)
-	if net.ParseIP(requestData.Target) == nil {
-		if frequency <= 0 || packetCount <= 0 {
-			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": "Please specify all parameters!"})
-			return
-		}
-		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": "Invalid IP provided!"})
+	msr := models.PingMeasurement{
+		Target:      requestData.Target,
+		PacketCount: requestData.PacketCount.Value,
+		Frequency:   requestData.Frequency.Value,
+		MtrMode:     requestData.MtrMode.Value,
+		Multipath:   requestData.Multipath.Value,
+		PmtuMode:    requestData.PmtuMode.Value,
+		PacketSize:  requestData.PacketSize.Value,
+		Interval:    requestData.Interval.Value,
+		TTL:         requestData.TTL.Value,
+		Timeout:     requestData.Timeout.Value,
+		DSCP:        requestData.DSCP.Value,
+		OwnerID:     user.ID,
+		TeamID:      user.TeamID,
+	}
+	tags, err := utils.NormalizeTags(requestData.Tags)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
 		return
-	} else if net.ParseIP(requestData.Target) != nil {
-		msr, err := utils.AddMsrToDatabase(requestData.Target, packetCount, frequency)
-		if err != nil {
-			message := fmt.Sprintf("Could not add measurement to database for processing, %v", err)
-			c.IndentedJSON(http.StatusOK,
-				gin.H{
-					"status":  http.StatusBadRequest,
-					"message": message,
-				})
-			return
-		}
-		scheduler.SchedulePingMeasurement(msr.ID, msr.Target, msr.PacketCount, msr.Frequency)
-		c.Header("HX-Trigger", "pageRefresh")
-		message := fmt.Sprintf("Measurement: %s was added successfully", msr.ID.String())
+	}
+	msr.Tags = tags
+	utils.ApplyPingDefaults(&msr)
+	if err := utils.ValidatePingSettings(msr); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	// Admins are trusted with as many measurements as they need
+	if err := utils.CheckMeasurementPolicy(msr, !auth.HasRole(user, auth.RoleAdmin)); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	msr, err = utils.AddMsrToDatabase(msr)
+	if err != nil {
+		message := fmt.Sprintf("Could not add measurement to database for processing, %v", err)
 		c.IndentedJSON(http.StatusOK,
 			gin.H{
-				"status":  http.StatusAccepted,
+				"status":  http.StatusBadRequest,
 				"message": message,
 			})
+		return
+	}
+	scheduler.SchedulePingMeasurement(msr.ID, msr.Target, msr.PacketCount, msr.Frequency)
+	audit.Record(c, audit.ActionCreate, audit.ObjectMeasurement, msr.ID.String(), nil, msr)
+	utils.AddMeasurementEvent(msr.ID, utils.EventCreated, user.Username, fmt.Sprintf("Measurement was created towards %s", msr.Target), nil)
+	c.Header("HX-Trigger", "pageRefresh")
+	message := fmt.Sprintf("Measurement: %s was added successfully", msr.ID.String())
+	c.IndentedJSON(http.StatusOK,
+		gin.H{
+			"status":  http.StatusAccepted,
+			"message": message,
+		})
+}
+
+func ApiUpdateMeasurement(c *gin.Context) {
+	before := c.MustGet("measurement").(models.PingMeasurement)
+	var updateData updateRequestData
+	if !bindRequest(c, &updateData) {
+		return
+	}
+	msr := before
+	// Settings left empty keep their current value
+	intSettings := []struct {
+		value IntField
+		field *int
+	}{
+		{updateData.PacketCount, &msr.PacketCount},
+		{updateData.Frequency, &msr.Frequency},
+		{updateData.PacketSize, &msr.PacketSize},
+		{updateData.Interval, &msr.Interval},
+		{updateData.TTL, &msr.TTL},
+		{updateData.Timeout, &msr.Timeout},
+		{updateData.DSCP, &msr.DSCP},
+	}
+	for _, setting := range intSettings {
+		if setting.value.Set {
+			*setting.field = setting.value.Value
+		}
+	}
+	floatSettings := []struct {
+		value FloatField
+		field *float64
+	}{
+		{updateData.ThresholdMinRtt, &msr.ThresholdMinRtt},
+		{updateData.ThresholdMaxRtt, &msr.ThresholdMaxRtt},
+		{updateData.ThresholdAvgRtt, &msr.ThresholdAvgRtt},
+		{updateData.ThresholdJitter, &msr.ThresholdJitter},
+		{updateData.ThresholdLoss, &msr.ThresholdLoss},
+	}
+	for _, setting := range floatSettings {
+		if setting.value.Set {
+			*setting.field = setting.value.Value
+		}
+	}
+	boolSettings := []struct {
+		value BoolField
+		field *bool
+	}{
+		{updateData.MtrMode, &msr.MtrMode},
+		{updateData.Multipath, &msr.Multipath},
+		{updateData.PmtuMode, &msr.PmtuMode},
+	}
+	for _, setting := range boolSettings {
+		if setting.value.Set {
+			*setting.field = setting.value.Value
+		}
+	}
+	if updateData.Tags != nil {
+		tags, err := utils.NormalizeTags(*updateData.Tags)
+		if err != nil {
+			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+			return
+		}
+		msr.Tags = tags
+	}
+	// A derived timeout follows the new packet count and interval
+	if !updateData.Timeout.Set && (msr.PacketCount != before.PacketCount || msr.Interval != before.Interval) {
+		msr.Timeout = 0
+	}
+	msr, changes, err := utils.UpdateMsrSettings(before, msr)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	// The job skips stopped measurements, so it follows the new frequency whatever the status
+	if msr.Frequency != before.Frequency {
+		scheduler.SchedulePingMeasurement(msr.ID, msr.Target, msr.PacketCount, msr.Frequency)
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ObjectMeasurement, msr.ID.String(), before, msr)
+	utils.AddMeasurementEvent(msr.ID, utils.EventUpdated, c.GetString("username"), utils.DescribeSettingChanges(changes), changes)
+	c.Header("HX-Trigger", "reloadTable")
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("Measurement: %s was updated.", msr.ID),
+		"data":    msr,
+	})
+}
+
+func ApiGetMeasurementEvents(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GetMeasurementEvents(msrID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
 	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
 }
 
 func ApiCheckTargetIP(c *gin.Context) {
@@ -211,6 +630,9 @@ func ApiCheckTargetIP(c *gin.Context) {
 		if targetIP.IsPrivate() {
 			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Please enter valid public IP address!"})
 			return
+		} else if _, denied := utils.DeniedPrefixFor(ipAddr); denied {
+			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "This IP address is not allowed as a target!"})
+			return
 		} else {
 			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "IP is valid!"})
 			return
@@ -223,6 +645,11 @@ func ApiCheckTargetIP(c *gin.Context) {
 
 func ApiGetVisitorInfo(c *gin.Context) {
 	ipAddr := c.Param("ip")
+	// Everyone may look up their own address, the rest of the visitors data is for admins
+	if user, _ := auth.CurrentUser(c); ipAddr != c.GetString("clientIP") && !auth.HasRole(user, auth.RoleAdmin) {
+		c.IndentedJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "Your role does not allow this action"})
+		return
+	}
 	var user models.SiteVisitor
 	if err := database.DB.First(&user, "ip_address = ?", ipAddr).Error; err != nil {
 		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
@@ -235,3 +662,624 @@ func ApiGetVisitorsChart(c *gin.Context) {
 	data := utils.GenerateVisitorsChart()
 	c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": data})
 }
+
+func ApiGetIPCacheStats(c *gin.Context) {
+	data := utils.GetIPCacheStats()
+	c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": data})
+}
+
+func ApiGetMeasurementStats(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not parse 'from', expected RFC3339 timestamp"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not parse 'to', expected RFC3339 timestamp"})
+			return
+		}
+	}
+	buckets, err := strconv.Atoi(c.DefaultQuery("buckets", strconv.Itoa(utils.DefaultHistogramBuckets)))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert buckets to integer"})
+		return
+	}
+	data, err := utils.GetMeasurementStats(msrID, from, to, buckets)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetAlertRules(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	data, err := utils.GetAlertRules(msrID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiCreateAlertRule(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	var ruleData ruleRequestData
+	if !bindRequest(c, &ruleData) {
+		return
+	}
+	rule, err := utils.AddAlertRule(models.AlertRule{
+		MsrID:     msrID,
+		Metric:    ruleData.Metric,
+		Operator:  ruleData.Operator,
+		Threshold: ruleData.Threshold.Value,
+		Window:    ruleData.Window.Value,
+	})
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectAlertRule, strconv.Itoa(int(rule.ID)), nil, rule)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": fmt.Sprintf("Alert rule: %d was added successfully", rule.ID),
+		"data":    rule,
+	})
+}
+
+func ApiDeleteAlertRule(c *gin.Context) {
+	msrID, ok := parseMsrID(c)
+	if !ok {
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("rule_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert rule_id to integer"})
+		return
+	}
+	var rule models.AlertRule
+	database.DB.First(&rule, "id = ? AND msr_id = ?", ruleID, msrID)
+	if err := utils.DeleteAlertRule(msrID, ruleID); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	audit.Record(c, audit.ActionDelete, audit.ObjectAlertRule, strconv.Itoa(ruleID), rule, nil)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("Alert rule: %d was deleted successfully", ruleID),
+	})
+}
+
+func reportSLA(c *gin.Context) (reports.SLA, error) {
+	sla := reports.SLA{MaxAvgRtt: reports.DefaultSlaMaxAvgRtt, MaxLoss: reports.DefaultSlaMaxLoss}
+	var err error
+	if value := c.Query("sla_rtt"); value != "" {
+		if sla.MaxAvgRtt, err = strconv.ParseFloat(value, 64); err != nil {
+			return sla, errors.New("Could not convert sla_rtt to a number")
+		}
+	}
+	if value := c.Query("sla_loss"); value != "" {
+		if sla.MaxLoss, err = strconv.ParseFloat(value, 64); err != nil {
+			return sla, errors.New("Could not convert sla_loss to a number")
+		}
+	}
+	return sla, nil
+}
+
+func visibleMsrIDs(c *gin.Context, requested string) ([]string, error) {
+	user, _ := auth.CurrentUser(c)
+	var msrIDs []string
+	query := auth.VisibleMeasurements(database.DB.Model(&models.PingMeasurement{}), user)
+	var requestedIDs []string
+	for _, msrID := range strings.Split(requested, ",") {
+		if msrID = strings.TrimSpace(msrID); msrID != "" {
+			requestedIDs = append(requestedIDs, msrID)
+		}
+	}
+	if len(requestedIDs) > 0 {
+		query = query.Where("id IN ?", requestedIDs)
+	}
+	if err := query.Pluck("id", &msrIDs).Error; err != nil {
+		return msrIDs, err
+	}
+	if len(msrIDs) < len(requestedIDs) || len(msrIDs) == 0 {
+		return msrIDs, errors.New("Measurement not found")
+	}
+	return msrIDs, nil
+}
+
+func ApiGetMeasurementReport(c *gin.Context) {
+	msrID := c.Param("id")
+	sla, err := reportSLA(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+		return
+	}
+	data, err := reports.GenerateReport([]string{msrID}, c.Param("period"), sla)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetReport(c *gin.Context) {
+	sla, err := reportSLA(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+		return
+	}
+	msrIDs, err := visibleMsrIDs(c, c.Query("ids"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotFound, "message": err.Error()})
+		return
+	}
+	data, err := reports.GenerateReport(msrIDs, c.Param("period"), sla)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func reportOwnerFilter(c *gin.Context) uint {
+	// Admins see every schedule, everyone else only their own
+	user, _ := auth.CurrentUser(c)
+	if auth.HasRole(user, auth.RoleAdmin) {
+		return 0
+	}
+	return user.ID
+}
+
+func ownedReportSchedule(c *gin.Context, scheduleID int) (models.ReportSchedule, error) {
+	schedule, err := reports.GetReportSchedule(scheduleID)
+	if err != nil {
+		return schedule, errors.Errorf("Report schedule: %d not found.", scheduleID)
+	}
+	if ownerID := reportOwnerFilter(c); ownerID != 0 && schedule.OwnerID != ownerID {
+		return schedule, errors.Errorf("Report schedule: %d not found.", scheduleID)
+	}
+	return schedule, nil
+}
+
+func ApiGetReportSchedules(c *gin.Context) {
+	data, err := reports.GetReportSchedules(reportOwnerFilter(c))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiCreateReportSchedule(c *gin.Context) {
+	var scheduleData scheduleRequestData
+	if !bindRequest(c, &scheduleData) {
+		return
+	}
+	// Schedules are pinned to the measurements visible to their creator
+	msrIDs, err := visibleMsrIDs(c, scheduleData.MsrIDs)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	user, _ := auth.CurrentUser(c)
+	schedule := models.ReportSchedule{
+		OwnerID:     user.ID,
+		Name:        scheduleData.Name,
+		Period:      scheduleData.Period,
+		MsrIDs:      strings.Join(msrIDs, ","),
+		Channel:     scheduleData.Channel,
+		Destination: scheduleData.Destination,
+		// Left at 0 the report defaults apply
+		SlaMaxAvgRtt: scheduleData.SlaRtt.Value,
+		SlaMaxLoss:   scheduleData.SlaLoss.Value,
+	}
+	schedule, err = reports.AddReportSchedule(schedule)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectReportSchedule, strconv.Itoa(int(schedule.ID)), nil, schedule)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": fmt.Sprintf("Report schedule: %d was added successfully", schedule.ID),
+		"data":    schedule,
+	})
+}
+
+func ApiRunReportSchedule(c *gin.Context) {
+	scheduleID, err := strconv.Atoi(c.Param("schedule_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert schedule_id to integer"})
+		return
+	}
+	schedule, err := ownedReportSchedule(c, scheduleID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	data, err := reports.RunReportSchedule(schedule)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	audit.Record(c, audit.ActionRun, audit.ObjectReportSchedule, strconv.Itoa(scheduleID), nil, data)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiDeleteReportSchedule(c *gin.Context) {
+	scheduleID, err := strconv.Atoi(c.Param("schedule_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert schedule_id to integer"})
+		return
+	}
+	schedule, err := ownedReportSchedule(c, scheduleID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	if err := reports.DeleteReportSchedule(scheduleID); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	audit.Record(c, audit.ActionDelete, audit.ObjectReportSchedule, strconv.Itoa(scheduleID), schedule, nil)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("Report schedule: %d was deleted successfully", scheduleID),
+	})
+}
+
+func ApiGetGeneratedReports(c *gin.Context) {
+	scheduleID := utils.ConvertStringToInt(c.Query("schedule_id"))
+	data, err := reports.GetGeneratedReports(scheduleID, reportOwnerFilter(c))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiDownloadGeneratedReport(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("report_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert report_id to integer"})
+		return
+	}
+	generated, err := reports.GetGeneratedReport(reportID)
+	if err == nil {
+		_, err = ownedReportSchedule(c, int(generated.ScheduleID))
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	switch c.Param("format") {
+	case "html":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=report-%d.html", generated.ID))
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(generated.HTML))
+	case "csv":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=report-%d.csv", generated.ID))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(generated.CSV))
+	default:
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Format should be one of html, csv"})
+	}
+}
+
+func ApiGetCurrentUser(c *gin.Context) {
+	user, _ := auth.CurrentUser(c)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   user,
+	})
+}
+
+func ApiChangePassword(c *gin.Context) {
+	var passwordData passwordRequestData
+	if !bindRequest(c, &passwordData) {
+		return
+	}
+	user, _ := auth.CurrentUser(c)
+	if err := auth.ChangePassword(user, passwordData.CurrentPassword, passwordData.NewPassword); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionChangePassword, audit.ObjectUser, strconv.Itoa(int(user.ID)), nil, nil)
+	auth.ClearSessionCookies(c)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "Password was changed successfully, please log in again",
+	})
+}
+
+func ApiCreateUser(c *gin.Context) {
+	var userData userRequestData
+	if !bindRequest(c, &userData) {
+		return
+	}
+	if userData.Role == "" {
+		userData.Role = auth.RoleViewer
+	}
+	teamID := userData.TeamID.Value
+	if teamID != 0 {
+		if err := database.DB.First(&models.Team{}, "id = ?", teamID).Error; err != nil {
+			c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": fmt.Sprintf("Team: %d not found", teamID)})
+			return
+		}
+	}
+	user, err := auth.CreateUser(userData.Username, userData.Password, userData.Role, uint(teamID))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectUser, strconv.Itoa(int(user.ID)), nil, user)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": fmt.Sprintf("User: %s was added successfully", user.Username),
+		"data":    user,
+	})
+}
+
+func ApiGetAPITokens(c *gin.Context) {
+	user, _ := auth.CurrentUser(c)
+	data, err := auth.GetAPITokens(user)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiCreateAPIToken(c *gin.Context) {
+	var tokenData tokenRequestData
+	if !bindRequest(c, &tokenData) {
+		return
+	}
+	user, _ := auth.CurrentUser(c)
+	token, apiToken, err := auth.CreateAPIToken(user, tokenData.Name, tokenData.Scope, tokenData.ExpiryDays.Value)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectAPIToken, strconv.Itoa(int(apiToken.ID)), nil, apiToken)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": "API token was created, copy it now as it will not be shown again",
+		"token":   token,
+		"data":    apiToken,
+	})
+}
+
+func ApiRevokeAPIToken(c *gin.Context) {
+	tokenID, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert token_id to integer"})
+		return
+	}
+	user, _ := auth.CurrentUser(c)
+	var apiToken models.APIToken
+	database.DB.First(&apiToken, "id = ? AND user_id = ?", tokenID, user.ID)
+	if err := auth.RevokeAPIToken(user, tokenID); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	audit.Record(c, audit.ActionRevoke, audit.ObjectAPIToken, strconv.Itoa(tokenID), apiToken, nil)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("API token: %d was revoked successfully", tokenID),
+	})
+}
+
+func ApiGetUsers(c *gin.Context) {
+	data, err := auth.GetUsers()
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiUpdateUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert user_id to integer"})
+		return
+	}
+	var userData userAccessRequestData
+	if !bindRequest(c, &userData) {
+		return
+	}
+	var before models.User
+	database.DB.First(&before, "id = ?", userID)
+	user, err := auth.UpdateUserAccess(userID, userData.Role, userData.TeamID.Value)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ObjectUser, strconv.Itoa(userID), before, user)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("User: %s was updated successfully", user.Username),
+		"data":    user,
+	})
+}
+
+func ApiGetTeams(c *gin.Context) {
+	data, err := auth.GetTeams()
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiCreateTeam(c *gin.Context) {
+	var teamData teamRequestData
+	if !bindRequest(c, &teamData) {
+		return
+	}
+	team, err := auth.CreateTeam(teamData.Name)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectTeam, strconv.Itoa(int(team.ID)), nil, team)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": fmt.Sprintf("Team: %s was added successfully", team.Name),
+		"data":    team,
+	})
+}
+
+func ApiGetAuditLogs(c *gin.Context) {
+	limit, ok := queryInt(c, "limit")
+	if !ok {
+		return
+	}
+	data, err := audit.GetAuditLogs(audit.Filter{
+		Username:   c.Query("username"),
+		Action:     c.Query("action"),
+		ObjectType: c.Query("object_type"),
+		ObjectID:   c.Query("object_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		Limit:      limit,
+	})
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiGetMeasurementPolicy(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   utils.GetMeasurementPolicy(),
+	})
+}
+
+func ApiUpdateMeasurementPolicy(c *gin.Context) {
+	var policyData policyRequestData
+	if !bindRequest(c, &policyData) {
+		return
+	}
+	before := utils.GetMeasurementPolicy()
+	policy := before
+	// Fields left empty keep their current value
+	if policyData.MinFrequency.Set {
+		policy.MinFrequency = policyData.MinFrequency.Value
+	}
+	if policyData.MaxPacketCount.Set {
+		policy.MaxPacketCount = policyData.MaxPacketCount.Value
+	}
+	if policyData.MaxMeasurementsPerUser.Set {
+		policy.MaxMeasurementsPerUser = policyData.MaxMeasurementsPerUser.Value
+	}
+	policy, err := utils.UpdateMeasurementPolicy(policy)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ObjectPolicy, "measurements", before, policy)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "Measurement policy was updated successfully",
+		"data":    policy,
+	})
+}
+
+func ApiGetDeniedPrefixes(c *gin.Context) {
+	data, err := utils.GetDeniedPrefixes()
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+func ApiCreateDeniedPrefix(c *gin.Context) {
+	var prefixData deniedPrefixRequestData
+	if !bindRequest(c, &prefixData) {
+		return
+	}
+	denied, err := utils.AddDeniedPrefix(prefixData.Prefix, prefixData.Reason)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusNotAcceptable, "message": err.Error()})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ObjectDeniedPrefix, strconv.Itoa(int(denied.ID)), nil, denied)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusAccepted,
+		"message": fmt.Sprintf("Prefix: %s was added to the deny-list", denied.Prefix),
+		"data":    denied,
+	})
+}
+
+func ApiDeleteDeniedPrefix(c *gin.Context) {
+	prefixID, err := strconv.Atoi(c.Param("prefix_id"))
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Could not convert prefix_id to integer"})
+		return
+	}
+	denied, err := utils.DeleteDeniedPrefix(prefixID)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Error: %s", err)})
+		return
+	}
+	audit.Record(c, audit.ActionDelete, audit.ObjectDeniedPrefix, strconv.Itoa(prefixID), denied, nil)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("Prefix: %s was removed from the deny-list", denied.Prefix),
+	})
+}
@@ -0,0 +1,166 @@
+package views
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI document describing every /api/v1 and /api/v2 route
+const OpenAPIPath = "static/openapi.json"
+
+// Request bodies documented in the spec, keyed by their component schema name
+var requestSchemas = map[string]any{
+	"MeasurementCreate":    requestData{},
+	"MeasurementUpdate":    updateRequestData{},
+	"AlertRuleCreate":      ruleRequestData{},
+	"ReportScheduleCreate": scheduleRequestData{},
+	"PasswordChange":       passwordRequestData{},
+	"UserCreate":           userRequestData{},
+	"UserAccessUpdate":     userAccessRequestData{},
+	"TeamCreate":           teamRequestData{},
+	"PolicyUpdate":         policyRequestData{},
+	"DeniedPrefixCreate":   deniedPrefixRequestData{},
+	"APITokenCreate":       tokenRequestData{},
+}
+
+var routeParamPattern = regexp.MustCompile(`:(\w+)`)
+
+type openAPISchema struct {
+	Type       string                   `json:"type"`
+	Properties map[string]openAPISchema `json:"properties"`
+	Required   []string                 `json:"required"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema struct {
+				Ref string `json:"$ref"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPISpec struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+func ApiGetOpenAPI(c *gin.Context) {
+	c.Header("Content-Type", "application/json")
+	c.File(OpenAPIPath)
+}
+
+func requestFieldType(fieldType reflect.Type) string {
+	switch fieldType {
+	case reflect.TypeOf(IntField{}):
+		return "integer"
+	case reflect.TypeOf(FloatField{}):
+		return "number"
+	case reflect.TypeOf(BoolField{}):
+		return "boolean"
+	}
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() == reflect.String {
+		return "string"
+	}
+	return fieldType.Kind().String()
+}
+
+func checkRequestSchema(name string, request any, schema openAPISchema) []string {
+	var problems []string
+	documented, read := map[string]bool{}, map[string]bool{}
+	for _, field := range schema.Required {
+		documented[field] = true
+	}
+	requestType := reflect.TypeOf(request)
+	for i := 0; i < requestType.NumField(); i++ {
+		field := requestType.Field(i)
+		fieldName := jsonFieldName(field)
+		read[fieldName] = true
+		property, ok := schema.Properties[fieldName]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("schema %s is missing field %s", name, fieldName))
+			continue
+		}
+		if fieldType := requestFieldType(field.Type); property.Type != fieldType {
+			problems = append(problems, fmt.Sprintf("schema %s documents field %s as %s, the handler reads %s", name, fieldName, property.Type, fieldType))
+		}
+		required := false
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			required = required || rule == "required"
+		}
+		if required != documented[fieldName] {
+			problems = append(problems, fmt.Sprintf("schema %s required list does not match the handler for field %s", name, fieldName))
+		}
+	}
+	for fieldName := range schema.Properties {
+		if read[fieldName] {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("schema %s documents field %s the handler does not read", name, fieldName))
+	}
+	return problems
+}
+
+// Compares the routes registered under prefix and the request structs with the spec
+func CheckAPIContract(routes gin.RoutesInfo, prefix string) []string {
+	data, err := os.ReadFile(OpenAPIPath)
+	if err != nil {
+		return []string{fmt.Sprintf("could not read %s, %v", OpenAPIPath, err)}
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return []string{fmt.Sprintf("could not parse %s, %v", OpenAPIPath, err)}
+	}
+	var problems []string
+	registered := map[string]bool{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, prefix+"/") {
+			continue
+		}
+		path := routeParamPattern.ReplaceAllString(strings.TrimPrefix(route.Path, prefix), "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+		if _, ok := spec.Paths[path][method]; !ok {
+			problems = append(problems, fmt.Sprintf("route %s %s is not documented", route.Method, path))
+		}
+	}
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			if !registered[method+" "+path] {
+				problems = append(problems, fmt.Sprintf("documented operation %s %s has no route", strings.ToUpper(method), path))
+			}
+			if operation.RequestBody == nil {
+				continue
+			}
+			if content, ok := operation.RequestBody.Content["application/json"]; ok {
+				name := strings.TrimPrefix(content.Schema.Ref, "#/components/schemas/")
+				if _, ok := requestSchemas[name]; !ok {
+					problems = append(problems, fmt.Sprintf("operation %s %s takes schema %s which no handler binds", strings.ToUpper(method), path, name))
+				}
+			}
+		}
+	}
+	for name, request := range requestSchemas {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("schema %s is not documented", name))
+			continue
+		}
+		problems = append(problems, checkRequestSchema(name, request, schema)...)
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,60 @@
+package views
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sngx13/pingernoid/auth"
+)
+
+// Routes shared by /api/v1 and /api/v2, the groups bring their own middleware
+func RegisterAPIRoutes(api *gin.RouterGroup) {
+	api.GET("/auth/me", ApiGetCurrentUser)
+	api.POST("/auth/password", auth.RequireSession(), ApiChangePassword)
+	api.GET("/users", auth.RequireRole(auth.RoleAdmin), ApiGetUsers)
+	api.POST("/users/create", auth.RequireRole(auth.RoleAdmin), ApiCreateUser)
+	api.POST("/users/:user_id/update", auth.RequireRole(auth.RoleAdmin), ApiUpdateUser)
+	api.GET("/teams", ApiGetTeams)
+	api.POST("/teams/create", auth.RequireRole(auth.RoleAdmin), ApiCreateTeam)
+	api.GET("/tokens", auth.RequireSession(), ApiGetAPITokens)
+	api.POST("/tokens/create", auth.RequireSession(), ApiCreateAPIToken)
+	api.POST("/tokens/:token_id/revoke", auth.RequireSession(), ApiRevokeAPIToken)
+	api.POST("/checks/target/verify", ApiCheckTargetIP)
+	api.GET("/measurements", ApiGetMeasurements)
+	api.GET("/measurements/:id", auth.MeasurementAccess(false), ApiGetMeasurement)
+	api.PATCH("/measurements/:id", auth.MeasurementAccess(true), ApiUpdateMeasurement)
+	api.GET("/measurements/:id/events", auth.MeasurementAccess(false), ApiGetMeasurementEvents)
+	api.GET("/measurements/:id/results", auth.MeasurementAccess(false), ApiGetMeasurementResults)
+	api.GET("/measurements/:id/alerts", auth.MeasurementAccess(false), ApiGetMeasurementAlerts)
+	api.GET("/measurements/:id/traceroute/path", auth.MeasurementAccess(false), ApiGetMeasurementTracePathGraph)
+	api.GET("/measurements/:id/traceroute/hops", auth.MeasurementAccess(false), ApiGetMeasurementTraceHops)
+	api.GET("/measurements/:id/samples", auth.MeasurementAccess(false), ApiGetMeasurementSamples)
+	api.GET("/measurements/:id/stats", auth.MeasurementAccess(false), ApiGetMeasurementStats)
+	api.GET("/measurements/:id/report/:period", auth.MeasurementAccess(false), ApiGetMeasurementReport)
+	api.GET("/reports/:period", ApiGetReport)
+	api.GET("/reports/schedules", ApiGetReportSchedules)
+	api.POST("/reports/schedules/create", auth.RequireRole(auth.RoleOperator), ApiCreateReportSchedule)
+	api.POST("/reports/schedules/:schedule_id/run", auth.RequireRole(auth.RoleOperator), ApiRunReportSchedule)
+	api.DELETE("/reports/schedules/:schedule_id/delete", auth.RequireRole(auth.RoleOperator), ApiDeleteReportSchedule)
+	api.GET("/reports/history", ApiGetGeneratedReports)
+	api.GET("/reports/history/:report_id/download/:format", ApiDownloadGeneratedReport)
+	api.GET("/measurements/:id/rules", auth.MeasurementAccess(false), ApiGetAlertRules)
+	api.POST("/measurements/:id/rules/create", auth.MeasurementAccess(true), ApiCreateAlertRule)
+	api.DELETE("/measurements/:id/rules/:rule_id/delete", auth.MeasurementAccess(true), ApiDeleteAlertRule)
+	api.GET("/measurements/:id/traceroute/topology/:time_range", auth.MeasurementAccess(false), ApiGetMeasurementTopologyGraph)
+	api.GET("/measurements/:id/paths", auth.MeasurementAccess(false), ApiGetMeasurementPaths)
+	api.GET("/measurements/:id/paths/multipath", auth.MeasurementAccess(false), ApiGetMeasurementMultipath)
+	api.GET("/measurements/:id/alert/:timestamp", auth.MeasurementAccess(false), ApiGetAlertDetails)
+	api.POST("/measurements/create", auth.RequireRole(auth.RoleOperator), ApiCreateMeasurement)
+	api.POST("/measurements/:id/stop", auth.MeasurementAccess(true), ApiStopMeasurement)
+	api.POST("/measurements/:id/restart", auth.MeasurementAccess(true), ApiRestartMeasurement)
+	api.DELETE("/measurements/:id/delete", auth.MeasurementAccess(true), ApiDeleteMeasurement)
+	api.GET("/measurements/:id/results/combined/:time_range", auth.MeasurementAccess(false), ApiGetMeasurementCombinedChartResults)
+	api.GET("/site/visitor/info/:ip", ApiGetVisitorInfo)
+	api.GET("/site/visitor/info/chart", auth.RequireRole(auth.RoleAdmin), ApiGetVisitorsChart)
+	api.GET("/site/cache/stats", auth.RequireRole(auth.RoleAdmin), ApiGetIPCacheStats)
+	api.GET("/audit", auth.RequireRole(auth.RoleAdmin), ApiGetAuditLogs)
+	api.GET("/policy", ApiGetMeasurementPolicy)
+	api.POST("/policy/update", auth.RequireRole(auth.RoleAdmin), ApiUpdateMeasurementPolicy)
+	api.GET("/policy/denylist", auth.RequireRole(auth.RoleAdmin), ApiGetDeniedPrefixes)
+	api.POST("/policy/denylist/create", auth.RequireRole(auth.RoleAdmin), ApiCreateDeniedPrefix)
+	api.DELETE("/policy/denylist/:prefix_id/delete", auth.RequireRole(auth.RoleAdmin), ApiDeleteDeniedPrefix)
+}
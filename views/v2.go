@@ -0,0 +1,131 @@
+package views
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine readable error codes used by the v2 API
+var v2ErrorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusUnprocessableEntity: "validation_failed",
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+}
+
+type V2Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details"`
+	RequestID string `json:"request_id"`
+}
+
+// Holds the v1 response back so it can be rewritten once the handler is done
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func v2ErrorCode(status int) string {
+	if code, ok := v2ErrorCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+	return "error"
+}
+
+func newV2Error(c *gin.Context, status int, message string, details any) V2Error {
+	return V2Error{Code: v2ErrorCode(status), Message: message, Details: details, RequestID: c.GetString("requestID")}
+}
+
+func v2Rewrite(c *gin.Context, written int, body []byte) (int, []byte) {
+	if !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+		return written, body
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return written, body
+	}
+	// v1 reports the outcome in the body, the HTTP status is mostly 200
+	var status int
+	if err := json.Unmarshal(fields["status"], &status); err != nil || status < 200 || status > 599 {
+		return written, body
+	}
+	delete(fields, "status")
+	switch status {
+	case http.StatusNotAcceptable:
+		status = http.StatusUnprocessableEntity
+	case http.StatusNoContent:
+		// A 204 can not carry the confirmation message
+		status = http.StatusOK
+	}
+	var rewritten []byte
+	var err error
+	if status >= http.StatusBadRequest {
+		var message string
+		json.Unmarshal(fields["message"], &message)
+		var details any
+		if data, ok := fields["data"]; ok {
+			details = data
+		}
+		rewritten, err = json.Marshal(gin.H{"error": newV2Error(c, status, message, details)})
+	} else {
+		fields["request_id"], _ = json.Marshal(c.GetString("requestID"))
+		rewritten, err = json.Marshal(fields)
+	}
+	if err != nil {
+		return written, body
+	}
+	return status, rewritten
+}
+
+func V2Envelope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		// A panicking handler leaves the 500 to the recovery middleware on the real writer
+		defer func() { c.Writer = writer.ResponseWriter }()
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		status, body := v2Rewrite(c, writer.status, writer.body.Bytes())
+		c.Writer.WriteHeader(status)
+		c.Writer.Write(body)
+	}
+}
@@ -0,0 +1,256 @@
+package views
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+)
+
+// Numbers and booleans arrive as JSON values from scripts and as strings from the htmx forms,
+// an empty string or null leaves the field unset
+type IntField struct {
+	Value   int
+	Set     bool
+	invalid bool
+}
+
+type FloatField struct {
+	Value   float64
+	Set     bool
+	invalid bool
+}
+
+type BoolField struct {
+	Value   bool
+	Set     bool
+	invalid bool
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Unquoted raw value, false when the field was left empty
+func rawFieldValue(data []byte) (string, bool) {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return "", false
+	}
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = strings.TrimSpace(unquoted)
+	}
+	return raw, raw != ""
+}
+
+func (f *IntField) UnmarshalJSON(data []byte) error {
+	raw, ok := rawFieldValue(data)
+	if !ok {
+		return nil
+	}
+	value, err := strconv.Atoi(raw)
+	f.Value, f.Set, f.invalid = value, err == nil, err != nil
+	return nil
+}
+
+func (f IntField) MarshalJSON() ([]byte, error) {
+	if !f.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.Value)
+}
+
+func (f *FloatField) UnmarshalJSON(data []byte) error {
+	raw, ok := rawFieldValue(data)
+	if !ok {
+		return nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	f.Value, f.Set, f.invalid = value, err == nil, err != nil
+	return nil
+}
+
+func (f FloatField) MarshalJSON() ([]byte, error) {
+	if !f.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.Value)
+}
+
+func (f *BoolField) UnmarshalJSON(data []byte) error {
+	raw, ok := rawFieldValue(data)
+	if !ok {
+		return nil
+	}
+	// Checkboxes post "on" when ticked
+	value, err := strconv.ParseBool(raw)
+	if raw == "on" || raw == "off" {
+		value, err = raw == "on", nil
+	}
+	f.Value, f.Set, f.invalid = value, err == nil, err != nil
+	return nil
+}
+
+func (f BoolField) MarshalJSON() ([]byte, error) {
+	if !f.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.Value)
+}
+
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Rules apply to the parsed value, a pointer keeps an explicit 0 apart from a missing field
+	engine.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		switch value := field.Interface().(type) {
+		case IntField:
+			if value.Set {
+				return &value.Value
+			}
+		case FloatField:
+			if value.Set {
+				return &value.Value
+			}
+		case BoolField:
+			if value.Set {
+				return &value.Value
+			}
+		}
+		return nil
+	}, IntField{}, FloatField{}, BoolField{})
+	engine.RegisterTagNameFunc(jsonFieldName)
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Values that could not be parsed at all, reported before any range checks
+func typeErrors(obj any) []FieldError {
+	var fieldErrors []FieldError
+	value := reflect.Indirect(reflect.ValueOf(obj))
+	for i := 0; i < value.NumField(); i++ {
+		name := jsonFieldName(value.Type().Field(i))
+		switch field := value.Field(i).Interface().(type) {
+		case IntField:
+			if field.invalid {
+				fieldErrors = append(fieldErrors, FieldError{name, "must be a whole number"})
+			}
+		case FloatField:
+			if field.invalid {
+				fieldErrors = append(fieldErrors, FieldError{name, "must be a number"})
+			}
+		case BoolField:
+			if field.invalid {
+				fieldErrors = append(fieldErrors, FieldError{name, "must be true or false"})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+func fieldErrorMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", err.Param())
+		}
+		return fmt.Sprintf("must be at least %s", err.Param())
+	case "max":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", err.Param())
+		}
+		return fmt.Sprintf("must be at most %s", err.Param())
+	case "gt":
+		return fmt.Sprintf("must be above %s", err.Param())
+	case "lt":
+		return fmt.Sprintf("must be below %s", err.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(err.Param()), ", "))
+	case "ip":
+		return "must be a valid IP address"
+	case "ip|cidr":
+		return "must be a valid IP address or CIDR prefix"
+	case "uuid":
+		return "must be a valid UUID"
+	}
+	return fmt.Sprintf("failed the %s check", err.Tag())
+}
+
+func validationErrors(obj any) []FieldError {
+	if fieldErrors := typeErrors(obj); len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	var fieldErrors []FieldError
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var errs validator.ValidationErrors
+		if !errors.As(err, &errs) {
+			return []FieldError{{"", err.Error()}}
+		}
+		for _, fieldErr := range errs {
+			fieldErrors = append(fieldErrors, FieldError{fieldErr.Field(), fieldErrorMessage(fieldErr)})
+		}
+	}
+	return fieldErrors
+}
+
+// Decodes and validates the JSON body, answering the request itself when it is not acceptable
+func bindRequest(c *gin.Context, obj any) bool {
+	if c.Request.Body == nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": "Request body should be a JSON object"})
+		return false
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": http.StatusBadRequest, "message": fmt.Sprintf("Request body should be a JSON object, %v", err)})
+		return false
+	}
+	fieldErrors := validationErrors(obj)
+	if len(fieldErrors) == 0 {
+		return true
+	}
+	problems := make([]string, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		problems[i] = strings.TrimSpace(fieldErr.Field + " " + fieldErr.Message)
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotAcceptable,
+		"message": fmt.Sprintf("Please correct the request: %s.", strings.Join(problems, ", ")),
+		"data":    fieldErrors,
+	})
+	return false
+}
+
+// Whole number query parameter, 0 when it is left out
+func queryInt(c *gin.Context, name string) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		c.IndentedJSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotAcceptable,
+			"message": fmt.Sprintf("Please correct the request: %s must be a whole number.", name),
+			"data":    []FieldError{{name, "must be a whole number"}},
+		})
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,202 @@
+package views
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sngx13/pingernoid/database"
+	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/reports"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// Templates and the OpenAPI document are read relative to the repository root
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func openTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&models.PingMeasurement{},
+		&models.MeasurementResults{},
+		&models.MeasurementResultAlerts{},
+		&models.MeasurementHopResults{},
+		&models.MeasurementPacketSamples{},
+		&models.ReportSchedule{},
+		&models.GeneratedReport{},
+		&models.User{},
+		&models.AuditLog{},
+		&models.MeasurementPaths{},
+		&models.MeasurementPathHops{},
+		&models.MeasurementResultPaths{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+}
+
+func TestRunAndDownloadReportSchedule(t *testing.T) {
+	openTestDB(t)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer webhook.Close()
+	msr := models.PingMeasurement{ID: uuid.New(), Target: "192.0.2.1", Frequency: 5, PacketCount: 10}
+	if err := database.DB.Create(&msr).Error; err != nil {
+		t.Fatal(err)
+	}
+	owner := models.User{ID: 1, Username: "owner", Role: "operator"}
+	schedule, err := reports.AddReportSchedule(models.ReportSchedule{
+		OwnerID:     owner.ID,
+		Name:        "weekly",
+		Period:      reports.PeriodWeek,
+		MsrIDs:      msr.ID.String(),
+		Channel:     reports.ChannelWebhook,
+		Destination: webhook.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := func(user models.User) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("user", user) })
+		router.POST("/reports/schedules/:schedule_id/run", ApiRunReportSchedule)
+		router.GET("/reports/history/:report_id/download/:format", ApiDownloadGeneratedReport)
+		return router
+	}
+	serve := func(user models.User, method, path string) (*httptest.ResponseRecorder, map[string]any) {
+		w := httptest.NewRecorder()
+		router(user).ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	w, body := serve(owner, http.MethodPost, fmt.Sprintf("/reports/schedules/%d/run", schedule.ID))
+	if body["status"] != float64(http.StatusOK) {
+		t.Fatalf("run: got %s", w.Body.String())
+	}
+	reportID := int(body["data"].(map[string]any)["id"].(float64))
+
+	w, _ = serve(owner, http.MethodGet, fmt.Sprintf("/reports/history/%d/download/csv", reportID))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("download: got %d %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		user   models.User
+		method string
+		path   string
+		status float64
+	}{
+		{"other user can not run", models.User{ID: 2, Role: "operator"}, http.MethodPost, fmt.Sprintf("/reports/schedules/%d/run", schedule.ID), http.StatusBadRequest},
+		{"other user can not download", models.User{ID: 2, Role: "operator"}, http.MethodGet, fmt.Sprintf("/reports/history/%d/download/html", reportID), http.StatusBadRequest},
+		{"admin can run", models.User{ID: 3, Role: "admin"}, http.MethodPost, fmt.Sprintf("/reports/schedules/%d/run", schedule.ID), http.StatusOK},
+		{"unknown schedule", owner, http.MethodPost, "/reports/schedules/999/run", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w, body := serve(test.user, test.method, test.path)
+		if body["status"] != test.status {
+			t.Errorf("%s: got %s", test.name, w.Body.String())
+		}
+	}
+}
+
+func TestAPIContract(t *testing.T) {
+	router := gin.New()
+	RegisterAPIRoutes(router.Group("/api/v1"))
+	if problems := CheckAPIContract(router.Routes(), "/api/v1"); len(problems) > 0 {
+		t.Errorf("API routes do not match %s:\n%s", OpenAPIPath, strings.Join(problems, "\n"))
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{"numbers", `{"target": "192.0.2.1", "packet_count": 10, "frequency": 5}`, nil},
+		{"numbers as strings", `{"target": "192.0.2.1", "packet_count": "10", "frequency": " 5 ", "mtr_mode": "on", "multipath": "false"}`, nil},
+		{"missing fields", `{"target": "192.0.2.1"}`, []FieldError{{"packet_count", "is required"}, {"frequency", "is required"}}},
+		{"empty strings are unset", `{"target": "192.0.2.1", "packet_count": "", "frequency": null}`, []FieldError{{"packet_count", "is required"}, {"frequency", "is required"}}},
+		{"explicit zero is set", `{"target": "192.0.2.1", "packet_count": 0, "frequency": 5}`, []FieldError{{"packet_count", "must be at least 1"}}},
+		{"out of range", `{"target": "192.0.2.1", "packet_count": 101, "frequency": 5, "packet_size": 10}`, []FieldError{{"packet_count", "must be at most 100"}, {"packet_size", "must be at least 24"}}},
+		{"invalid int", `{"target": "192.0.2.1", "packet_count": "ten", "frequency": 1.5}`, []FieldError{{"packet_count", "must be a whole number"}, {"frequency", "must be a whole number"}}},
+		{"invalid bool", `{"target": "192.0.2.1", "packet_count": 10, "frequency": 5, "mtr_mode": "yes please"}`, []FieldError{{"mtr_mode", "must be true or false"}}},
+		{"invalid ip", `{"target": "example", "packet_count": 10, "frequency": 5}`, []FieldError{{"target", "must be a valid IP address"}}},
+	}
+	for _, test := range tests {
+		var request requestData
+		if err := json.Unmarshal([]byte(test.body), &request); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := validationErrors(&request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFloatFieldValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{"number", `{"threshold_jitter": 2.5, "threshold_loss": "10"}`, nil},
+		{"zero is not above zero", `{"threshold_jitter": 0}`, []FieldError{{"threshold_jitter", "must be above 0"}}},
+		{"loss below 100", `{"threshold_loss": 100}`, []FieldError{{"threshold_loss", "must be below 100"}}},
+		{"invalid float", `{"threshold_avg_rtt": "fast"}`, []FieldError{{"threshold_avg_rtt", "must be a number"}}},
+		{"invalid bool", `{"pmtu_mode": 2}`, []FieldError{{"pmtu_mode", "must be true or false"}}},
+	}
+	for _, test := range tests {
+		var request updateRequestData
+		if err := json.Unmarshal([]byte(test.body), &request); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := validationErrors(&request); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMalformedMeasurementID(t *testing.T) {
+	handlers := map[string]gin.HandlerFunc{
+		"/hops":      ApiGetMeasurementTraceHops,
+		"/paths":     ApiGetMeasurementPaths,
+		"/multipath": ApiGetMeasurementMultipath,
+		"/samples":   ApiGetMeasurementSamples,
+		"/stats":     ApiGetMeasurementStats,
+		"/rules":     ApiGetAlertRules,
+	}
+	router := gin.New()
+	for path, handler := range handlers {
+		router.GET("/measurements/:id"+path, handler)
+	}
+	for path := range handlers {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/measurements/not-a-uuid"+path, nil))
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if body["status"] != float64(http.StatusBadRequest) {
+			t.Errorf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
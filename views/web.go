@@ -2,10 +2,14 @@ package views
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sngx13/pingernoid/auth"
 	"github.com/sngx13/pingernoid/database"
 	"github.com/sngx13/pingernoid/models"
+	"github.com/sngx13/pingernoid/reports"
 )
 
 func WebVisitorsPage(c *gin.Context) {
@@ -14,8 +18,24 @@ func WebVisitorsPage(c *gin.Context) {
 		http.StatusOK,
 		"visitors.html",
 		gin.H{
-			"title":  "Visitors Page",
-			"userIP": userIP,
+			"title":    "Visitors Page",
+			"userIP":   userIP,
+			"username": c.GetString("username"),
+			"role":     c.GetString("role"),
+		},
+	)
+}
+
+func WebAuditPage(c *gin.Context) {
+	userIP := c.MustGet("clientIP").(string)
+	c.HTML(
+		http.StatusOK,
+		"audit.html",
+		gin.H{
+			"title":    "Audit Log",
+			"userIP":   userIP,
+			"username": c.GetString("username"),
+			"role":     c.GetString("role"),
 		},
 	)
 }
@@ -26,8 +46,10 @@ func WebDashboardPage(c *gin.Context) {
 		http.StatusOK,
 		"dashboard.html",
 		gin.H{
-			"title":  "Dashboard Page",
-			"userIP": userIP,
+			"title":    "Dashboard Page",
+			"userIP":   userIP,
+			"username": c.GetString("username"),
+			"role":     c.GetString("role"),
 		},
 	)
 }
@@ -39,15 +61,108 @@ func WebGetMeasurement(c *gin.Context) {
 	var pageErrors error
 	if err := database.DB.Preload("Results").Preload("Alerts").Where("id = ?", msrID).First(&msr).Error; err != nil {
 		pageErrors = err
+	} else if user, _ := auth.CurrentUser(c); !auth.CanViewMeasurement(user, msr) {
+		msr = models.PingMeasurement{}
+		pageErrors = errors.New("Measurement not found")
 	}
 	c.HTML(
 		http.StatusOK,
 		"measurement.html",
 		gin.H{
-			"title":  "Measurement Page",
-			"userIP": userIP,
-			"data":   msr,
-			"errors": pageErrors,
+			"title":    "Measurement Page",
+			"userIP":   userIP,
+			"username": c.GetString("username"),
+			"role":     c.GetString("role"),
+			"data":     msr,
+			"errors":   pageErrors,
+		},
+	)
+}
+
+func WebGetReport(c *gin.Context) {
+	userIP := c.MustGet("clientIP").(string)
+	var pageErrors error
+	sla, err := reportSLA(c)
+	if err != nil {
+		pageErrors = err
+	}
+	var report reports.Report
+	msrIDs, err := visibleMsrIDs(c, c.Query("ids"))
+	if err != nil {
+		pageErrors = err
+	} else if report, err = reports.GenerateReport(msrIDs, c.Param("period"), sla); err != nil {
+		pageErrors = err
+	}
+	c.HTML(
+		http.StatusOK,
+		"report.html",
+		gin.H{
+			"title":    "Report Page",
+			"userIP":   userIP,
+			"username": c.GetString("username"),
+			"role":     c.GetString("role"),
+			"data":     report,
+			"errors":   pageErrors,
 		},
 	)
 }
+
+func loginRedirectTarget(next string) string {
+	// Only local paths, anything else could send the user to another site after login
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func WebLoginPage(c *gin.Context) {
+	if _, ok := auth.CurrentUser(c); ok {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.HTML(
+		http.StatusOK,
+		"login.html",
+		gin.H{
+			"title": "Login Page",
+			"next":  loginRedirectTarget(c.Query("next")),
+		},
+	)
+}
+
+func WebLogin(c *gin.Context) {
+	next := loginRedirectTarget(c.PostForm("next"))
+	user, err := auth.Authenticate(c.PostForm("username"), c.PostForm("password"))
+	if err == nil {
+		var token string
+		var session models.Session
+		if token, session, err = auth.CreateSession(user); err == nil {
+			auth.SetSessionCookies(c, token, session)
+			c.Redirect(http.StatusFound, next)
+			return
+		}
+	}
+	c.HTML(
+		http.StatusUnauthorized,
+		"login.html",
+		gin.H{
+			"title":  "Login Page",
+			"next":   next,
+			"errors": errors.Wrap(err, "Login failed"),
+		},
+	)
+}
+
+func WebLogout(c *gin.Context) {
+	if token, err := c.Cookie(auth.SessionCookieName); err == nil {
+		auth.DeleteSession(token)
+	}
+	auth.ClearSessionCookies(c)
+	// HTMX follows redirects itself, it has to be told to navigate instead
+	if c.GetHeader("HX-Request") == "true" {
+		c.Header("HX-Redirect", "/login")
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Redirect(http.StatusFound, "/login")
+}